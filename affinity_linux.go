@@ -0,0 +1,85 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// the cpus atomic was allowed to run on when it started, before it may have pinned itself
+var availableCPUs *unix.CPUSet
+
+// checkCPUs makes sure that all the given cpus are available to atomic.
+func checkCPUs(cpus []int) error {
+	if availableCPUs == nil {
+		var available unix.CPUSet
+		if err := unix.SchedGetaffinity(0, &available); err != nil {
+			return err
+		}
+		availableCPUs = &available
+	}
+	for _, cpu := range cpus {
+		if !availableCPUs.IsSet(cpu) {
+			return fmt.Errorf("cpu %d isn't available", cpu)
+		}
+	}
+	return nil
+}
+
+func cpuSet(cpus []int) *unix.CPUSet {
+	var set unix.CPUSet
+	for _, cpu := range cpus {
+		set.Set(cpu)
+	}
+	return &set
+}
+
+// startWithAffinity starts the command restricted to the given cpus. A child inherits the affinity
+// of the thread which forks it, so the mask of the calling thread is swapped for the duration of the start.
+func startWithAffinity(cmd *exec.Cmd, cpus []int) error {
+	if len(cpus) == 0 {
+		return cmd.Start()
+	}
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	var previous unix.CPUSet
+	if err := unix.SchedGetaffinity(0, &previous); err != nil {
+		return err
+	}
+	if err := unix.SchedSetaffinity(0, cpuSet(cpus)); err != nil {
+		return err
+	}
+	startErr := cmd.Start()
+	if err := unix.SchedSetaffinity(0, &previous); err != nil {
+		return err
+	}
+	return startErr
+}
+
+// pinRunner restricts all the threads of atomic to the given cpus, new threads inherit the mask.
+func pinRunner(cpus []int) error {
+	tasks, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		return err
+	}
+	set := cpuSet(cpus)
+	for _, task := range tasks {
+		tid, err := strconv.Atoi(task.Name())
+		if err != nil {
+			continue
+		}
+		// threads may exit in the meantime
+		if err := unix.SchedSetaffinity(tid, set); err != nil && !errors.Is(err, unix.ESRCH) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os/exec"
+)
+
+var errAffinityUnsupported = errors.New("cpu affinity is only available on linux")
+
+func checkCPUs(cpus []int) error {
+	return errAffinityUnsupported
+}
+
+func startWithAffinity(cmd *exec.Cmd, cpus []int) error {
+	if len(cpus) == 0 {
+		return cmd.Start()
+	}
+	return errAffinityUnsupported
+}
+
+func pinRunner(cpus []int) error {
+	return errAffinityUnsupported
+}
@@ -0,0 +1,206 @@
+// Package bench benchmarks Go functions in-process with the statistics of atomic, producing the same
+// results as the atomic CLI, which can be exported and plotted just like those of benchmarked commands.
+//
+//	result, err := bench.Run(ctx, "json.Marshal", func(ctx context.Context) error {
+//		_, err := json.Marshal(payload)
+//		return err
+//	}, bench.Options{Warmup: 100})
+//	if err != nil {
+//		return err
+//	}
+//	fmt.Print(bench.Summary(result))
+//	err = bench.Export([]*bench.Result{result}, []string{"json"}, "marshal", time.Microsecond)
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shravanasati/atomic/internal"
+)
+
+// Result is the result of the benchmark of a function, the same structure as the one of a command benchmarked
+// by the atomic CLI. Its timings are in microseconds. A run which returned an error (only recorded with
+// [Options.IgnoreError]) has an exit code of 1 in `ExitCodes`.
+type Result = internal.SpeedResult
+
+// Func is a function to benchmark. It should give up when the context is done.
+type Func func(ctx context.Context) error
+
+// Options tells how to benchmark a function. The zero value benchmarks the function like the atomic CLI
+// benchmarks a command, determining the number of runs automatically.
+type Options struct {
+	// Runs is the number of runs to perform, determined from a single run of the function when zero:
+	// at least MinRuns runs lasting at least MinDuration, and at most MaxRuns runs.
+	Runs        int
+	MinRuns     int
+	MaxRuns     int
+	MinDuration time.Duration
+	// Warmup is the number of runs to perform before the measured ones.
+	Warmup int
+	// Timeout is the time a single run may take, the benchmark fails when it takes longer. Zero means no timeout.
+	// The context of the run is done once the timeout is over, but a function can't be stopped from the outside:
+	// one which ignores its context only fails once it returns.
+	Timeout time.Duration
+	// IgnoreError records the runs which return an error as failed runs instead of failing the benchmark.
+	IgnoreError bool
+}
+
+// the defaults of the atomic CLI, except for the maximum number of runs which is bounded since functions
+// may take nanoseconds and every run is recorded
+const (
+	defaultMinRuns     = 10
+	defaultMaxRuns     = 100_000
+	defaultMinDuration = 3 * time.Second
+)
+
+// ErrTimeout is returned when a run of the function takes longer than [Options.Timeout].
+var ErrTimeout = errors.New("the run timed out")
+
+func (o Options) withDefaults() Options {
+	if o.MinRuns <= 0 {
+		o.MinRuns = defaultMinRuns
+	}
+	if o.MaxRuns <= 0 {
+		o.MaxRuns = defaultMaxRuns
+	}
+	if o.MinDuration <= 0 {
+		o.MinDuration = defaultMinDuration
+	}
+	return o
+}
+
+// runs the function once, returning the time it took
+func runOnce(ctx context.Context, fn Func, timeout time.Duration) (time.Duration, error) {
+	runCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	init := time.Now()
+	err := fn(runCtx)
+	elapsed := time.Since(init)
+	if timeout > 0 && elapsed > timeout {
+		return elapsed, fmt.Errorf("%w after %s", ErrTimeout, timeout)
+	}
+	return elapsed, err
+}
+
+// Run benchmarks the function under the given name, which is used as the command of the result.
+// It stops as soon as the context is done, returning the context's error.
+func Run(ctx context.Context, name string, fn Func, opts Options) (*Result, error) {
+	opts = opts.withDefaults()
+	var times []float64
+	var exitCodes []int
+	// runs the function once, recording the run unless it's a warmup
+	run := func(i int, warmup bool) (time.Duration, error) {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		elapsed, err := runOnce(ctx, fn, opts.Timeout)
+		if errors.Is(err, ErrTimeout) || (err != nil && !opts.IgnoreError) {
+			what := "run"
+			if warmup {
+				what = "warmup run"
+			}
+			return 0, fmt.Errorf("%s %d of %s failed: %w", what, i+1, name, err)
+		}
+		if !warmup {
+			exitCode := 0
+			if err != nil {
+				exitCode = 1
+			}
+			times = append(times, float64(elapsed.Nanoseconds())/1e3)
+			exitCodes = append(exitCodes, exitCode)
+		}
+		return elapsed, nil
+	}
+
+	for i := 0; i < opts.Warmup; i++ {
+		if _, err := run(i, true); err != nil {
+			return nil, err
+		}
+	}
+	runs := opts.Runs
+	if runs <= 0 {
+		elapsed, err := run(0, false)
+		if err != nil {
+			return nil, err
+		}
+		runs = internal.DetermineRuns(elapsed, opts.MinRuns, opts.MaxRuns, opts.MinDuration)
+	}
+	for i := len(times); i < runs; i++ {
+		if _, err := run(i, false); err != nil {
+			return nil, err
+		}
+	}
+
+	average := internal.CalculateAverage(times)
+	return &Result{
+		Command:           name,
+		AverageElapsed:    average,
+		StandardDeviation: internal.CalculateStandardDeviation(times, average),
+		Min:               slices.Min(times),
+		Max:               slices.Max(times),
+		Times:             times,
+		ExitCodes:         exitCodes,
+	}, nil
+}
+
+// Summary returns the summary of the result, as printed by the atomic CLI.
+func Summary(result *Result) string {
+	return internal.NewPrintableResult().FromSpeedResult(*result, time.Microsecond).String()
+}
+
+// HasOutliers tells whether statistical outliers were detected among the runs of the result,
+// in which case the benchmark should be repeated on a quieter system or with more warmup runs.
+func HasOutliers(result *Result) bool {
+	return internal.TestOutliers(slices.Clone(result.Times))
+}
+
+// Compare prints how many times faster the fastest of the results is than the others, as the atomic CLI
+// does after benchmarking several commands. It sorts the results by their mean and sets their relative means.
+func Compare(results []*Result) {
+	internal.RelativeSummary(results, internal.TimeMetric)
+}
+
+// converts copies of the results to the given time unit, leaving the results untouched
+func inTimeUnit(results []*Result, timeUnit time.Duration) []*Result {
+	converted := make([]*Result, len(results))
+	for i, r := range results {
+		c := *r
+		c.Times = slices.Clone(r.Times)
+		converted[i] = &c
+	}
+	internal.ModifyTimeUnit(converted, timeUnit)
+	return converted
+}
+
+// Export writes the results to the file in all the given formats (json, csv, markdown or md, and txt), with
+// the timings in the given time unit. The extension of every format is added to the filename. The formats
+// which can be written are written even if others can't, the returned error tells which ones couldn't be.
+func Export(results []*Result, formats []string, filename string, timeUnit time.Duration) error {
+	formats, err := internal.VerifyExportFormats(strings.Join(formats, ","))
+	if err != nil {
+		return err
+	}
+	return internal.Export(formats, filename, inTimeUnit(results, timeUnit), nil, timeUnit, nil)
+}
+
+// Plot draws the results in all the given plot types (histogram, boxplot, errorbar or bar, or all of them
+// with all), with the timings in the given time unit.
+func Plot(results []*Result, formats []string, timeUnit time.Duration) error {
+	if !slices.Equal(formats, []string{"all"}) {
+		var err error
+		if formats, err = internal.VerifyPlotFormats(strings.Join(formats, ",")); err != nil {
+			return err
+		}
+	}
+	internal.Plot(formats, inTimeUnit(results, timeUnit), timeUnit)
+	return nil
+}
@@ -0,0 +1,128 @@
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func sleeper(d time.Duration) Func {
+	return func(ctx context.Context) error {
+		select {
+		case <-time.After(d):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	result, err := Run(context.Background(), "sleep", sleeper(time.Millisecond), Options{Runs: 5, Warmup: 2})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Command != "sleep" || len(result.Times) != 5 || !reflect.DeepEqual(result.ExitCodes, []int{0, 0, 0, 0, 0}) {
+		t.Errorf("Run() = %+v", result)
+	}
+	if result.Min < 1000 || result.AverageElapsed < result.Min || result.Max < result.AverageElapsed {
+		t.Errorf("Run() timings aren't in microseconds: min %v, mean %v, max %v", result.Min, result.AverageElapsed, result.Max)
+	}
+}
+
+func TestRunDeterminesRuns(t *testing.T) {
+	result, err := Run(context.Background(), "sleep", sleeper(time.Millisecond), Options{MinRuns: 3, MaxRuns: 8, MinDuration: time.Millisecond})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(result.Times) != 3 {
+		t.Errorf("Run() performed %d runs, want the minimum of 3", len(result.Times))
+	}
+
+	result, err = Run(context.Background(), "noop", func(context.Context) error { return nil }, Options{MaxRuns: 8})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(result.Times) != 8 {
+		t.Errorf("Run() performed %d runs, want the maximum of 8", len(result.Times))
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	errFailed := errors.New("failed")
+	calls := 0
+	flaky := func(context.Context) error {
+		calls++
+		if calls%2 == 0 {
+			return errFailed
+		}
+		return nil
+	}
+
+	if _, err := Run(context.Background(), "flaky", flaky, Options{Runs: 4}); !errors.Is(err, errFailed) {
+		t.Errorf("Run() error = %v, want %v", err, errFailed)
+	}
+
+	calls = 0
+	result, err := Run(context.Background(), "flaky", flaky, Options{Runs: 4, IgnoreError: true})
+	if err != nil {
+		t.Fatalf("Run() with IgnoreError error = %v", err)
+	}
+	if !reflect.DeepEqual(result.ExitCodes, []int{0, 1, 0, 1}) || result.FailedRuns() != 2 {
+		t.Errorf("Run() with IgnoreError exit codes = %v", result.ExitCodes)
+	}
+
+	if _, err := Run(context.Background(), "slow", sleeper(time.Second), Options{Runs: 2, Timeout: 10 * time.Millisecond, IgnoreError: true}); !errors.Is(err, ErrTimeout) {
+		t.Errorf("Run() error = %v, want %v", err, ErrTimeout)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Run(ctx, "sleep", sleeper(time.Millisecond), Options{Runs: 2}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestExport(t *testing.T) {
+	result, err := Run(context.Background(), "sleep", sleeper(time.Millisecond), Options{Runs: 3})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	mean := result.AverageElapsed
+
+	filename := filepath.Join(t.TempDir(), "summary")
+	if err := Export([]*Result{result}, []string{"json"}, filename, time.Millisecond); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if result.AverageElapsed != mean {
+		t.Errorf("Export() modified the result")
+	}
+	content, err := os.ReadFile(filename + ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exported struct {
+		TimeUnit string    `json:"time_unit"`
+		Results  []*Result `json:"results"`
+	}
+	if err := json.Unmarshal(content, &exported); err != nil {
+		t.Fatal(err)
+	}
+	if exported.TimeUnit != "ms" || len(exported.Results) != 1 || exported.Results[0].Command != "sleep" || exported.Results[0].AverageElapsed > mean/100 {
+		t.Errorf("Export() wrote %s", content)
+	}
+
+	if err := Export([]*Result{result}, []string{"yaml"}, filename, time.Millisecond); err == nil {
+		t.Errorf("Export() accepted an invalid format")
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing", "summary")
+	if err := Export([]*Result{result}, []string{"json", "csv"}, missing, time.Millisecond); err == nil {
+		t.Errorf("Export() to a missing directory succeeded")
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestBuildCommand(t *testing.T) {
+	tests := []struct {
+		name      string
+		command   string
+		shellArgs []string
+		want      []string
+		wantErr   bool
+	}{
+		{"split like a shell", `grep -r "type x" .`, nil, []string{"grep", "-r", "type x", "."}, false},
+		{"single quotes", `echo 'a "b"'`, nil, []string{"echo", `a "b"`}, false},
+		{"unterminated quote", `echo "a`, nil, nil, true},
+		{"verbatim to the shell", `grep -r "type x" . | wc -l`, []string{"/bin/sh", "-c"}, []string{"/bin/sh", "-c", `grep -r "type x" . | wc -l`}, false},
+		{"quotes kept for the shell", `echo "it's" \$HOME`, []string{"bash", "--norc", "-c"}, []string{"bash", "--norc", "-c", `echo "it's" \$HOME`}, false},
+		{"cmd.exe", `echo "a b" & ver`, []string{"cmd.exe", "/d", "/c"}, []string{"cmd.exe", "/d", "/c", `echo "a b" & ver`}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildCommand(tt.command, tt.shellArgs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("buildCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !slices.Equal(got, tt.want) {
+				t.Errorf("buildCommand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildCommandKeepsShellArgs(t *testing.T) {
+	shellArgs := make([]string, 2, 3)
+	copy(shellArgs, []string{"sh", "-c"})
+	first, _ := buildCommand("echo first", shellArgs)
+	second, _ := buildCommand("echo second", shellArgs)
+	if first[2] != "echo first" || second[2] != "echo second" {
+		t.Errorf("buildCommand() shares the shell arguments: %q, %q", first, second)
+	}
+}
+
+func TestParseArgv(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []string
+		wantErr bool
+	}{
+		{`["grep", "-r", "a b"]`, []string{"grep", "-r", "a b"}, false},
+		{`["echo", "\"quoted\"", "it's"]`, []string{"echo", `"quoted"`, "it's"}, false},
+		{`["true"]`, []string{"true"}, false},
+		{`[]`, nil, true},
+		{`["", "x"]`, nil, true},
+		{`grep -r x`, nil, true},
+		{`["grep", 1]`, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseArgv(tt.command)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("parseArgv(%s) error = %v, wantErr %v", tt.command, err, tt.wantErr)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("parseArgv(%s) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestCmdExeCommandLine(t *testing.T) {
+	tests := []struct {
+		args   []string
+		want   string
+		wantOk bool
+	}{
+		{[]string{"cmd.exe", "/d", "/c", `echo "a b" & ver`}, `cmd.exe /d /s /c "echo "a b" & ver"`, true},
+		{[]string{`C:\Windows\System32\CMD.EXE`, "/c", `"C:\Program Files\x.exe" "arg"`}, `C:\Windows\System32\CMD.EXE /s /c ""C:\Program Files\x.exe" "arg""`, true},
+		{[]string{`C:\my tools\cmd.exe`, "/C", "dir"}, `"C:\my tools\cmd.exe" /s /C "dir"`, true},
+		{[]string{"pwsh", "-Command", "echo 1"}, "", false},
+		{[]string{"cmd.exe", "/d", "/k", "echo 1"}, "", false},
+		{[]string{"cmd.exe", "/c"}, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := cmdExeCommandLine(tt.args)
+		if got != tt.want || ok != tt.wantOk {
+			t.Errorf("cmdExeCommandLine(%q) = %q, %v, want %q, %v", tt.args, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
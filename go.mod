@@ -5,12 +5,12 @@ go 1.21
 require (
 	github.com/google/shlex v0.0.0-20191202100458-e7afc7fbc510
 	golang.org/x/mod v0.14.0
+	golang.org/x/sys v0.14.0
 )
 
 require (
 	github.com/mitchellh/colorstring v0.0.0-20190213212951-d06e56a500db
 	github.com/rivo/uniseg v0.4.4 // indirect
-	golang.org/x/sys v0.14.0 // indirect
 	golang.org/x/term v0.14.0 // indirect
 )
 
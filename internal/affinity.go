@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidCPUList = errors.New("invalid cpu list")
+
+// ParseCPUList parses a list of CPUs in the format used by taskset and /sys, i.e. comma separated
+// CPU numbers and inclusive ranges, e.g. `0,2,4-7`. The returned CPUs are sorted and unique.
+func ParseCPUList(spec string) ([]int, error) {
+	var cpus []int
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		startString, endString, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(strings.TrimSpace(startString))
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("%w: `%s` is not a cpu number", ErrInvalidCPUList, part)
+		}
+		end := start
+		if isRange {
+			end, err = strconv.Atoi(strings.TrimSpace(endString))
+			if err != nil || end < start {
+				return nil, fmt.Errorf("%w: `%s` is not a valid range of cpus", ErrInvalidCPUList, part)
+			}
+		}
+		for cpu := start; cpu <= end; cpu++ {
+			if !slices.Contains(cpus, cpu) {
+				cpus = append(cpus, cpu)
+			}
+		}
+	}
+	if len(cpus) == 0 {
+		return nil, fmt.Errorf("%w: no cpus given", ErrInvalidCPUList)
+	}
+	slices.Sort(cpus)
+	return cpus, nil
+}
+
+// FormatCPUList formats sorted CPUs in the same format [ParseCPUList] accepts, collapsing
+// consecutive CPUs into ranges.
+func FormatCPUList(cpus []int) string {
+	var parts []string
+	for i := 0; i < len(cpus); {
+		j := i
+		for j+1 < len(cpus) && cpus[j+1] == cpus[j]+1 {
+			j++
+		}
+		if j == i {
+			parts = append(parts, strconv.Itoa(cpus[i]))
+		} else {
+			parts = append(parts, strconv.Itoa(cpus[i])+"-"+strconv.Itoa(cpus[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
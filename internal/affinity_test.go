@@ -0,0 +1,52 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCPUList(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    []int
+		wantErr bool
+	}{
+		{"single", "2", []int{2}, false},
+		{"list", "3, 2", []int{2, 3}, false},
+		{"range", "0,4-6", []int{0, 4, 5, 6}, false},
+		{"duplicates", "1,0-2", []int{0, 1, 2}, false},
+		{"negative", "-1", nil, true},
+		{"reversed range", "3-1", nil, true},
+		{"not a number", "a", nil, true},
+		{"empty", ",", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCPUList(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCPUList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseCPUList() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatCPUList(t *testing.T) {
+	tests := []struct {
+		cpus []int
+		want string
+	}{
+		{[]int{2}, "2"},
+		{[]int{2, 3}, "2-3"},
+		{[]int{0, 2, 3, 4, 7}, "0,2-4,7"},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := FormatCPUList(tt.cpus); got != tt.want {
+			t.Errorf("FormatCPUList(%v) = %v, want %v", tt.cpus, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/shlex"
+)
+
+var ErrInvalidEnv = errors.New("invalid environment variable")
+
+// ParseEnvVar checks that the given environment variable is of the form `KEY=VALUE`, the value may be empty.
+func ParseEnvVar(variable string) (string, error) {
+	key, _, found := strings.Cut(variable, "=")
+	if !found || strings.TrimSpace(key) == "" || strings.ContainsAny(key, " \t\n") || strings.ContainsRune(variable, 0) {
+		return "", fmt.Errorf("%w: expected `KEY=VALUE`, got `%s`", ErrInvalidEnv, variable)
+	}
+	return variable, nil
+}
+
+// ParseEnvList parses a space separated list of `KEY=VALUE` environment variables, in which the values
+// containing spaces are quoted like in a shell, e.g. `GOMAXPROCS=1 GOFLAGS="-count=1 -v"`.
+func ParseEnvList(spec string) ([]string, error) {
+	words, err := shlex.Split(spec)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidEnv, err)
+	}
+	variables := make([]string, 0, len(words))
+	for _, word := range words {
+		variable, err := ParseEnvVar(word)
+		if err != nil {
+			return nil, err
+		}
+		variables = append(variables, variable)
+	}
+	return variables, nil
+}
+
+// CleanEnv returns the minimal environment the commands get with --clean-env: the PATH of atomic, so that the
+// same executables are found, and a pinned locale and timezone.
+func CleanEnv() []string {
+	return []string{"PATH=" + os.Getenv("PATH"), "LC_ALL=C", "TZ=UTC"}
+}
+
+// EnvMap returns the given `KEY=VALUE` environment variables as a map, the last value of a key winning.
+func EnvMap(variables []string) map[string]string {
+	if len(variables) == 0 {
+		return nil
+	}
+	env := make(map[string]string, len(variables))
+	for _, variable := range variables {
+		key, value, _ := strings.Cut(variable, "=")
+		env[key] = value
+	}
+	return env
+}
@@ -0,0 +1,46 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseEnvList(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    []string
+		wantErr bool
+	}{
+		{"single", "GOMAXPROCS=1", []string{"GOMAXPROCS=1"}, false},
+		{"several", "GOMAXPROCS=8  GOGC=off", []string{"GOMAXPROCS=8", "GOGC=off"}, false},
+		{"quoted", `GOFLAGS="-count=1 -v" EMPTY=`, []string{"GOFLAGS=-count=1 -v", "EMPTY="}, false},
+		{"equals in value", "OPTS=a=b", []string{"OPTS=a=b"}, false},
+		{"empty", "", []string{}, false},
+		{"no value", "GOMAXPROCS", nil, true},
+		{"no key", "=1", nil, true},
+		{"unterminated quote", `A="b`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseEnvList(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseEnvList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseEnvList() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvMap(t *testing.T) {
+	got := EnvMap([]string{"A=1", "B=x=y", "A=2", "C="})
+	want := map[string]string{"A": "2", "B": "x=y", "C": ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("EnvMap() = %v, want %v", got, want)
+	}
+	if EnvMap(nil) != nil {
+		t.Errorf("EnvMap(nil) isn't nil")
+	}
+}
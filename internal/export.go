@@ -2,28 +2,59 @@ package internal
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
 	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
 )
 
 var summaryNoColor = `
-Executed Command:   {{ .Command }} 
-Total runs:         {{ .Runs }} 
-Average time taken: {{ .AverageElapsed }} ± {{ .StandardDeviation }} [User: {{ .AverageUser }}, System: {{ .AverageSystem }}]
+{{ if .Name }}Command Name:       {{ .Name }} 
+{{ end }}Executed Command:   {{ .Command }} 
+{{ if .Parameters }}Parameters:         {{ .Parameters }} 
+{{ end }}Total runs:         {{ .Runs }} {{ if .Partial }}(partial, the benchmark was interrupted){{ end }}
+Average time taken: {{ .AverageElapsed }} ± {{ .StandardDeviation }}{{ if .AverageUser }} [User: {{ .AverageUser }}, System: {{ .AverageSystem }}]{{ end }}
 Range:              {{ .Min }} ... {{ .Max }}
-`
+{{ if .Precision }}Precision:          {{ .Precision }}
+{{ end }}{{ if .AverageMemory }}Peak memory:        {{ .AverageMemory }} [Min: {{ .MinMemory }}, Max: {{ .MaxMemory }}]
+{{ end }}{{ if .ContextSwitches }}Context switches:   {{ .ContextSwitches }}
+Page faults:        {{ .PageFaults }}
+Block I/O:          {{ .BlockIO }}
+{{ end }}{{ if .Throughput }}Throughput:         {{ .Throughput }} [Jobs: {{ .Jobs }}]
+Latency:            {{ .Latency }}
+{{ end }}{{ range .PerfCounters }}{{ printf "%-20s" (printf "%s:" .Name) }}{{ . }}
+{{ end }}{{ if .Failures }}Failed runs:        {{ .Failures }}
+{{ end }}{{ if .Accepted }}Accepted runs:      {{ .Accepted }}
+{{ end }}{{ if .Timeouts }}Timed out runs:     {{ .Timeouts }}
+{{ end }}{{ if .Retries }}Retried runs:       {{ .Retries }}
+{{ end }}{{ if .Discarded }}Discarded runs:     {{ .Discarded }}
+{{ end }}`
 
 var summaryColor = `
-${yellow}Executed Command:   ${green}{{ .Command }} ${reset}
-${yellow}Total runs:         ${green}{{ .Runs }} ${reset}
-${yellow}Average time taken: ${green}{{ .AverageElapsed }} ± {{ .StandardDeviation }} ${reset} [User: ${blue}{{ .AverageUser }}${reset}, System: ${blue}{{ .AverageSystem }}${reset}]
+{{ if .Name }}${yellow}Command Name:       ${green}{{ .Name }} ${reset}
+{{ end }}${yellow}Executed Command:   ${green}{{ .Command }} ${reset}
+{{ if .Parameters }}${yellow}Parameters:         ${green}{{ .Parameters }} ${reset}
+{{ end }}${yellow}Total runs:         ${green}{{ .Runs }} ${reset}{{ if .Partial }}${red}(partial, the benchmark was interrupted)${reset}{{ end }}
+${yellow}Average time taken: ${green}{{ .AverageElapsed }} ± {{ .StandardDeviation }} ${reset}{{ if .AverageUser }} [User: ${blue}{{ .AverageUser }}${reset}, System: ${blue}{{ .AverageSystem }}${reset}]{{ end }}
 ${yellow}Range:              ${green}{{ .Min }} ... {{ .Max }} ${reset}
-`
+{{ if .Precision }}${yellow}Precision:          ${blue}{{ .Precision }} ${reset}
+{{ end }}{{ if .AverageMemory }}${yellow}Peak memory:        ${green}{{ .AverageMemory }} ${reset}[Min: ${blue}{{ .MinMemory }}${reset}, Max: ${blue}{{ .MaxMemory }}${reset}]
+{{ end }}{{ if .ContextSwitches }}${yellow}Context switches:   ${blue}{{ .ContextSwitches }} ${reset}
+${yellow}Page faults:        ${blue}{{ .PageFaults }} ${reset}
+${yellow}Block I/O:          ${blue}{{ .BlockIO }} ${reset}
+{{ end }}{{ if .Throughput }}${yellow}Throughput:         ${green}{{ .Throughput }} ${reset}[Jobs: ${blue}{{ .Jobs }}${reset}]
+${yellow}Latency:            ${blue}{{ .Latency }} ${reset}
+{{ end }}{{ range .PerfCounters }}${yellow}{{ printf "%-20s" (printf "%s:" .Name) }}${green}{{ . }} ${reset}
+{{ end }}{{ if .Failures }}${yellow}Failed runs:        ${red}{{ .Failures }} ${reset}
+{{ end }}{{ if .Accepted }}${yellow}Accepted runs:      ${blue}{{ .Accepted }} ${reset}
+{{ end }}{{ if .Timeouts }}${yellow}Timed out runs:     ${red}{{ .Timeouts }} ${reset}
+{{ end }}{{ if .Retries }}${yellow}Retried runs:       ${red}{{ .Retries }} ${reset}
+{{ end }}{{ if .Discarded }}${yellow}Discarded runs:     ${red}{{ .Discarded }} ${reset}
+{{ end }}`
 
 // Consolify prints the benchmark summary of the Result struct to the console, with color codes.
 func (result *PrintableResult) String() string {
@@ -34,7 +65,7 @@ func (result *PrintableResult) String() string {
 		text = summaryNoColor
 	} else {
 		text = format(summaryColor,
-			map[string]string{"blue": BLUE, "yellow": YELLOW, "green": GREEN, "cyan": CYAN, "reset": RESET})
+			map[string]string{"blue": BLUE, "yellow": YELLOW, "green": GREEN, "cyan": CYAN, "red": RED, "reset": RESET})
 	}
 
 	var bobTheBuilder strings.Builder
@@ -50,62 +81,97 @@ func (result *PrintableResult) String() string {
 	return bobTheBuilder.String()
 }
 
+// reports that the file was written, by its absolute path if it can be determined
+func logWritten(what, filename string) {
+	if absPath, err := filepath.Abs(filename); err == nil {
+		filename = absPath
+	}
+	Log("green", fmt.Sprintf("Successfully wrote %s to `%s`.", what, filename))
+}
+
 // textify writes the benchmark summary of the Result struct to a text file.
-func textify(results []*PrintableResult, filename string) {
-	// temporarily turn off colors so that [PrintableResult.String] used non-colored summary
+func textify(results []*PrintableResult, filename string) error {
+	// temporarily turn off colors so that [PrintableResult.String] uses the non-colored summary
 	origVal := NO_COLOR
 	NO_COLOR = true
-	var ferr error
-	var f *os.File
-	defer func() {
-		NO_COLOR = origVal
-		absPath, err := filepath.Abs(filename)
-		if err != nil {
-			Log("red", "unable to get the absolute path for text file: "+err.Error())
-			return
-		} else {
-			if ferr == nil {
-				Log("green", "Successfully wrote benchmark summary to `"+absPath+"`.")
-			}
-		}
-	}()
-
-	f, ferr = os.Create(filename)
-	if ferr != nil {
-		Log("red", "Failed to create the file.")
-	}
-	defer f.Close()
-
+	text := ""
 	for _, r := range results {
-		f.WriteString(r.String() + "\n")
+		text += r.String() + "\n"
 	}
+	NO_COLOR = origVal
 
+	if err := writeToFile(text, filename); err != nil {
+		return err
+	}
+	logWritten("benchmark summary", filename)
+	return nil
 }
 
-func markdownify(results []*SpeedResult, filename, timeUnit string) {
+func markdownify(results []*SpeedResult, pivot *PivotTable, filename, timeUnit string) error {
+	// every scanned variable gets its own column right after the command
+	paramNames := parameterNames(results)
+	paramHeader := ""
+	paramSeparator := ""
+	for _, name := range paramNames {
+		paramHeader += " " + name + " |"
+		paramSeparator += " --- |"
+	}
+	// the memory columns are only included if the memory was measured (not on windows), and the results
+	// are either compared by their time or by their memory
+	withMemory := slices.ContainsFunc(results, func(r *SpeedResult) bool { return r.AverageMemory != 0 })
+	withRelativeMemory := slices.ContainsFunc(results, func(r *SpeedResult) bool { return r.RelativeMemory != 0 })
+	relativeHeader := ""
+	relativeSeparator := ""
+	if !withRelativeMemory {
+		relativeHeader += " Relative |"
+		relativeSeparator += " -------- |"
+	}
+	if withMemory {
+		relativeHeader += " Memory [MiB] |"
+		relativeSeparator += " ------ |"
+	}
+	if withRelativeMemory {
+		relativeHeader += " Relative memory |"
+		relativeSeparator += " --------------- |"
+	}
 	text := `
 # atomic-summary
 
-| Command | Runs | Average [${timeUnit}] | User [${timeUnit}] | System [${timeUnit}] | Min [${timeUnit}] | Max [${timeUnit}] | Relative |
-| ------- | ---- | ------- | ---- | ------ | --- | --- | -------- |
+| Command |${paramHeader} Runs | Average [${timeUnit}] | User [${timeUnit}] | System [${timeUnit}] | Min [${timeUnit}] | Max [${timeUnit}] |${relativeHeader}
+| ------- |${paramSeparator} ---- | ------- | ---- | ------ | --- | --- |${relativeSeparator}
 `
-	text = format(text, map[string]string{"timeUnit": timeUnit})
+	text = format(text, map[string]string{"timeUnit": timeUnit, "paramHeader": paramHeader, "paramSeparator": paramSeparator, "relativeHeader": relativeHeader, "relativeSeparator": relativeSeparator})
 	for _, r := range results {
-		text += fmt.Sprintf("`%s` | %d | %.2f ± %.2f | %.2f | %.2f | %.2f | %.2f | %.2f ± %.2f \n", r.Command, len(r.Times), r.AverageElapsed, r.StandardDeviation, r.AverageUser, r.AverageSystem, r.Min, r.Max, r.RelativeMean, r.RelativeStddev)
+		paramValues := ""
+		for _, name := range paramNames {
+			paramValues += " " + r.Parameters[name] + " |"
+		}
+		// a persistent shell doesn't tell the user and system times
+		user, system := "n/a", "n/a"
+		if !r.PersistentShell {
+			user, system = fmt.Sprintf("%.2f", r.AverageUser), fmt.Sprintf("%.2f", r.AverageSystem)
+		}
+		text += fmt.Sprintf("`%s` |%s %d | %.2f ± %.2f | %s | %s | %.2f | %.2f ", r.DisplayName(), paramValues, len(r.Times), r.AverageElapsed, r.StandardDeviation, user, system, r.Min, r.Max)
+		if !withRelativeMemory {
+			text += fmt.Sprintf("| %.2f ± %.2f ", r.RelativeMean, r.RelativeStddev)
+		}
+		if withMemory {
+			text += fmt.Sprintf("| %.2f ", r.AverageMemory/(1<<20))
+		}
+		if withRelativeMemory {
+			text += fmt.Sprintf("| %.2f ± %.2f ", r.RelativeMemory, r.RelativeMemoryStddev)
+		}
+		text += "\n"
 	}
-
-	err := writeToFile(text, filename)
-	if err != nil {
-		Log("red", "error in writing to file: "+filename+"\nerror: "+err.Error())
-		return
+	if pivot != nil {
+		text += markdownPivot(pivot, timeUnit)
 	}
 
-	absPath, err := filepath.Abs(filename)
-	if err != nil {
-		Log("red", "unable to get the absolute path for markdown file: "+err.Error())
-	} else {
-		Log("green", "Successfully wrote benchmark summary to `"+absPath+"`.")
+	if err := writeToFile(text, filename); err != nil {
+		return err
 	}
+	logWritten("benchmark summary", filename)
+	return nil
 }
 
 // jsonify converts the Result struct to JSON.
@@ -114,25 +180,62 @@ func jsonify(data any) ([]byte, error) {
 }
 
 // csvify converts the Result struct to CSV.
-func csvify(results []*SpeedResult, filename string) {
-	text := "command,runs,average_elapsed,stddev,average_user,average_system,min,max,relative_average,relative_stddev\n"
-
-	for _, r := range results {
-		text += fmt.Sprintf("%s,%d,%f,%f,%f,%f,%f,%f,%f,%f\n", r.Command, len(r.Times), r.AverageElapsed, r.StandardDeviation, r.AverageUser, r.AverageSystem, r.Min, r.Max, r.RelativeMean, r.RelativeStddev)
+func csvify(results []*SpeedResult, filename string) error {
+	text := "command,runs,average_elapsed,stddev,average_user,average_system,min,max,relative_average,relative_stddev,output_mode,output_bytes,failed_runs,exit_codes,mean_memory,min_memory,max_memory,relative_memory,relative_memory_stddev,cpus,jobs,throughput,timed_out_runs,retried_runs,discarded_runs,partial,accepted_runs"
+	// the average resource usage is only appended when it was collected
+	withResourceUsage := slices.ContainsFunc(results, func(r *SpeedResult) bool { return r.ResourceUsage != nil })
+	if withResourceUsage {
+		text += "," + strings.Join(resourceUsageColumns, ",")
+	}
+	// the mean of every perf counter is appended as a perf_<name> column
+	perfNames := perfCounterNames(results)
+	for _, name := range perfNames {
+		text += ",perf_" + name
 	}
+	// scanned variables are appended as parameter_<name> columns
+	paramNames := parameterNames(results)
+	for _, name := range paramNames {
+		text += ",parameter_" + name
+	}
+	text += "\n"
 
-	err := writeToFile(text, filename)
-	if err != nil {
-		Log("red", "error in writing to file: "+filename+"\nerror: "+err.Error())
-		return
+	for _, r := range results {
+		// exit codes of the runs are separated by spaces, to keep them in a single column
+		exitCodes := strings.Join(MapFunc[[]int, []string](strconv.Itoa, r.ExitCodes), " ")
+		// a persistent shell doesn't tell the user and system times, they're left empty
+		user, system := "", ""
+		if !r.PersistentShell {
+			user, system = fmt.Sprintf("%f", r.AverageUser), fmt.Sprintf("%f", r.AverageSystem)
+		}
+		text += fmt.Sprintf("%s,%d,%f,%f,%s,%s,%f,%f,%f,%f,%s,%f,%d,%s,%f,%f,%f,%f,%f,%s,%d,%f,%d,%d,%d,%t,%d", r.DisplayName(), len(r.Times), r.AverageElapsed, r.StandardDeviation, user, system, r.Min, r.Max, r.RelativeMean, r.RelativeStddev, r.OutputMode, r.OutputBytes, r.FailedRuns(), exitCodes, r.AverageMemory, r.MinMemory, r.MaxMemory, r.RelativeMemory, r.RelativeMemoryStddev, FormatCPUList(r.CPUs), max(r.Jobs, 1), r.Throughput, r.TimedOutRuns(), r.Retries, len(r.Discarded), r.Partial, r.AcceptedRuns())
+		if withResourceUsage {
+			usage := r.ResourceUsage
+			if usage == nil {
+				usage = &ResourceUsage{}
+			}
+			for _, v := range usage.values() {
+				text += fmt.Sprintf(",%f", v)
+			}
+		}
+		for _, name := range perfNames {
+			index := slices.IndexFunc(r.PerfCounters, func(pc PerfCounter) bool { return pc.Name == name })
+			if index < 0 {
+				text += ","
+			} else {
+				text += fmt.Sprintf(",%f", r.PerfCounters[index].Mean)
+			}
+		}
+		for _, name := range paramNames {
+			text += "," + r.Parameters[name]
+		}
+		text += "\n"
 	}
 
-	absPath, err := filepath.Abs(filename)
-	if err != nil {
-		Log("red", "unable to get the absolute path for csv file: "+err.Error())
-	} else {
-		Log("green", "Successfully wrote benchmark summary to `"+absPath+"`.")
+	if err := writeToFile(text, filename); err != nil {
+		return err
 	}
+	logWritten("benchmark summary", filename)
+	return nil
 }
 
 func VerifyExportFormats(formats string) ([]string, error) {
@@ -146,40 +249,46 @@ func VerifyExportFormats(formats string) ([]string, error) {
 	return formatList, nil
 }
 
-func Export(formats []string, filename string, results []*SpeedResult, timeUnit time.Duration) {
+// Export writes the results in all the given formats. If pivot is not nil, its matrices are
+// also written to the markdown summary and to a separate csv file. The metadata describes the benchmark
+// as a whole (such as the cpus atomic was pinned to), and is only included in the json export.
+// A format which can't be written doesn't keep the others from being written, the errors of all of them are returned.
+func Export(formats []string, filename string, results []*SpeedResult, pivot *PivotTable, timeUnit time.Duration, metadata map[string]any) error {
+	var errs []error
 	for _, format := range formats {
+		var err error
 		switch format {
 		case "json":
 			jsonMap := map[string]any{"time_unit": timeUnit.String()[1:], "results": results}
-			jsonData, err := jsonify(jsonMap)
-			if err != nil {
-				panic("unable to convert to json: " + err.Error())
-			}
-			filename := addExtension(filename, "json")
-			err = writeToFile(string(jsonData), filename)
-			if err != nil {
-				Log("red", "an unknown error occured in writing to file: "+err.Error())
-				return
+			for key, value := range metadata {
+				jsonMap[key] = value
 			}
-			absPath, err := filepath.Abs(filename)
-			if err != nil {
-				Log("red", "an unknown error occured in getting the full path to the file: "+filename+"\nerror: "+err.Error())
-				return
+			var jsonData []byte
+			if jsonData, err = jsonify(jsonMap); err == nil {
+				filename := addExtension(filename, "json")
+				if err = writeToFile(string(jsonData), filename); err == nil {
+					logWritten("benchmark summary", filename)
+				}
 			}
-			Log("green", fmt.Sprintf("Successfully wrote benchmark summary to `%s`.", absPath))
 
 		case "csv":
-			filename := addExtension(filename, "csv")
-			csvify(results, filename)
+			err = csvify(results, addExtension(filename, "csv"))
+			if err == nil && pivot != nil {
+				err = csvPivot(pivot, addExtension(strings.TrimSuffix(filename, ".csv")+"-pivot", "csv"))
+			}
 
 		case "markdown", "md":
 			filename := addExtension(filename, "md")
-			markdownify(results, filename, timeUnit.String()[1:])
+			err = markdownify(results, pivot, filename, timeUnit.String()[1:])
 
 		case "txt":
-			printables := MapFunc[[]*SpeedResult, []*PrintableResult](func(r *SpeedResult) *PrintableResult { return NewPrintableResult().FromSpeedResult(*r) }, results)
+			printables := MapFunc[[]*SpeedResult, []*PrintableResult](func(r *SpeedResult) *PrintableResult { return NewPrintableResult().FromSpeedResult(*r, timeUnit) }, results)
 			filename := addExtension(filename, "txt")
-			textify(printables, filename)
+			err = textify(printables, filename)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to write the %s export: %w", format, err))
 		}
 	}
+	return errors.Join(errs...)
 }
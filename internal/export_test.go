@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// returns the header of the table markdownify writes for the results
+func markdownHeader(t *testing.T, results []*SpeedResult) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "summary.md")
+	if err := markdownify(results, nil, filename, "ms"); err != nil {
+		t.Fatalf("markdownify() error = %v", err)
+	}
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.HasPrefix(line, "| Command |") {
+			return line
+		}
+	}
+	t.Fatalf("markdownify() wrote no table header:\n%s", content)
+	return ""
+}
+
+func TestMarkdownifyMemoryColumns(t *testing.T) {
+	const timeColumns = "| Command | Runs | Average [ms] | User [ms] | System [ms] | Min [ms] | Max [ms] |"
+	tests := []struct {
+		name    string
+		results []*SpeedResult
+		want    string
+	}{
+		{"no memory", []*SpeedResult{{Command: "a"}, {Command: "b"}}, timeColumns + " Relative |"},
+		{"memory", []*SpeedResult{{Command: "a", AverageMemory: 1 << 20}, {Command: "b"}}, timeColumns + " Relative | Memory [MiB] |"},
+		// compared by their memory, the results have no relative time
+		{"relative memory", []*SpeedResult{{Command: "a", AverageMemory: 1 << 20, RelativeMemory: 1}, {Command: "b", AverageMemory: 2 << 20, RelativeMemory: 2}}, timeColumns + " Memory [MiB] | Relative memory |"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := markdownHeader(t, tt.results); got != tt.want {
+				t.Errorf("markdownify() header = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkdownifyPersistentShell(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "summary.md")
+	results := []*SpeedResult{{Command: "a", AverageUser: 1, AverageSystem: 1, PersistentShell: true}}
+	if err := markdownify(results, nil, filename, "ms"); err != nil {
+		t.Fatalf("markdownify() error = %v", err)
+	}
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "| n/a | n/a |"; !strings.Contains(string(content), want) {
+		t.Errorf("markdownify() wrote:\n%s\nwant the user and system times as %q", content, want)
+	}
+}
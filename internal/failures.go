@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidFailureBudget = errors.New("invalid failure budget")
+var ErrInvalidExitCodes = errors.New("invalid exit codes")
+
+// FailureBudget is how many runs of a command may fail, and be discarded, before the whole command fails.
+// It's either a number of runs or a fraction of the runs. The zero value tolerates no failed run.
+type FailureBudget struct {
+	Runs     int
+	Fraction float64
+}
+
+// ParseFailureBudget parses the value of the --max-failures flag, either a number of runs like `3` or
+// a percentage of the runs like `5%`.
+func ParseFailureBudget(spec string) (FailureBudget, error) {
+	spec = strings.TrimSpace(spec)
+	if percent, isPercent := strings.CutSuffix(spec, "%"); isPercent {
+		value, err := strconv.ParseFloat(strings.TrimSpace(percent), 64)
+		if err != nil || math.IsNaN(value) || value < 0 || value > 100 {
+			return FailureBudget{}, fmt.Errorf("%w: `%s` is not a percentage between 0%% and 100%%", ErrInvalidFailureBudget, spec)
+		}
+		return FailureBudget{Fraction: value / 100}, nil
+	}
+	runs, err := strconv.Atoi(spec)
+	if err != nil || runs < 0 {
+		return FailureBudget{}, fmt.Errorf("%w: expected a number of runs like 3 or a percentage like 5%%, got `%s`", ErrInvalidFailureBudget, spec)
+	}
+	return FailureBudget{Runs: runs}, nil
+}
+
+// IsZero tells whether the budget tolerates no failed run at all.
+func (fb FailureBudget) IsZero() bool {
+	return fb.Runs == 0 && fb.Fraction == 0
+}
+
+// Allows tells whether the given number of failed runs is within the budget, out of the given number of runs.
+func (fb FailureBudget) Allows(failures, runs int) bool {
+	if fb.Fraction > 0 {
+		return float64(failures) <= fb.Fraction*float64(runs)
+	}
+	return failures <= fb.Runs
+}
+
+func (fb FailureBudget) String() string {
+	if fb.Fraction > 0 {
+		return strconv.FormatFloat(fb.Fraction*100, 'f', -1, 64) + "% of the runs"
+	}
+	if fb.Runs == 1 {
+		return "1 run"
+	}
+	return strconv.Itoa(fb.Runs) + " runs"
+}
+
+// ParseExitCodes parses a comma separated list of exit codes, e.g. `1,3`.
+func ParseExitCodes(spec string) ([]int, error) {
+	var codes []int
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		code, err := strconv.Atoi(part)
+		if err != nil || code < 0 {
+			return nil, fmt.Errorf("%w: `%s` is not an exit code", ErrInvalidExitCodes, part)
+		}
+		codes = append(codes, code)
+	}
+	if len(codes) == 0 {
+		return nil, fmt.Errorf("%w: no exit code given", ErrInvalidExitCodes)
+	}
+	return codes, nil
+}
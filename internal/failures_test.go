@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFailureBudget(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    FailureBudget
+		wantErr bool
+	}{
+		{"3", FailureBudget{Runs: 3}, false},
+		{"0", FailureBudget{}, false},
+		{"5%", FailureBudget{Fraction: 0.05}, false},
+		{" 12.5 %", FailureBudget{Fraction: 0.125}, false},
+		{"-1", FailureBudget{}, true},
+		{"101%", FailureBudget{}, true},
+		{"1.5", FailureBudget{}, true},
+		{"some", FailureBudget{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseFailureBudget(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ParseFailureBudget(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParseFailureBudget(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestFailureBudgetAllows(t *testing.T) {
+	tests := []struct {
+		budget   FailureBudget
+		failures int
+		runs     int
+		want     bool
+	}{
+		{FailureBudget{}, 1, 100, false},
+		{FailureBudget{Runs: 2}, 2, 10, true},
+		{FailureBudget{Runs: 2}, 3, 1000, false},
+		{FailureBudget{Fraction: 0.1}, 1, 10, true},
+		{FailureBudget{Fraction: 0.1}, 2, 10, false},
+		{FailureBudget{Fraction: 0.1}, 1, 5, false},
+	}
+	for _, tt := range tests {
+		if got := tt.budget.Allows(tt.failures, tt.runs); got != tt.want {
+			t.Errorf("%v.Allows(%d, %d) = %v, want %v", tt.budget, tt.failures, tt.runs, got, tt.want)
+		}
+	}
+}
+
+func TestParseExitCodes(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []int
+		wantErr bool
+	}{
+		{"1,3", []int{1, 3}, false},
+		{" 2 ,", []int{2}, false},
+		{"", nil, true},
+		{"1,-2", nil, true},
+		{"one", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseExitCodes(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ParseExitCodes(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseExitCodes(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
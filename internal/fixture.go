@@ -0,0 +1,78 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+var ErrInvalidFixture = errors.New("invalid fixture")
+
+// CheckFixture checks that the given fixture of the --isolate flag is a directory.
+func CheckFixture(fixture string) error {
+	info, err := os.Stat(fixture)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidFixture, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%w: `%s` is not a directory", ErrInvalidFixture, fixture)
+	}
+	return nil
+}
+
+// CopyTree copies the directory tree rooted at src into the existing directory dst, preserving the permissions
+// of the files and recreating the symbolic links as they are. The directories stay writable by the owner,
+// so that the copy can be filled and removed.
+func CopyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case entry.IsDir():
+			if rel == "." {
+				return os.Chmod(dst, info.Mode().Perm()|0o700)
+			}
+			return os.Mkdir(target, info.Mode().Perm()|0o700)
+		case entry.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case entry.Type().IsRegular():
+			return copyFile(path, target, info.Mode().Perm())
+		default:
+			return fmt.Errorf("%w: `%s` is neither a file, a directory nor a symbolic link", ErrInvalidFixture, path)
+		}
+	})
+}
+
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
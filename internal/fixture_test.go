@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCopyTree(t *testing.T) {
+	src := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "sub", "empty"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "sub", "run.sh"), []byte("echo hi"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "data.txt"), []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	symlinks := runtime.GOOS != "windows"
+	if symlinks {
+		if err := os.Symlink("data.txt", filepath.Join(src, "link")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dst := t.TempDir()
+	if err := CopyTree(src, dst); err != nil {
+		t.Fatalf("CopyTree() error = %v", err)
+	}
+	if content, err := os.ReadFile(filepath.Join(dst, "data.txt")); err != nil || string(content) != "data" {
+		t.Errorf("CopyTree() copied data.txt as %q, %v", content, err)
+	}
+	if info, err := os.Stat(filepath.Join(dst, "sub", "empty")); err != nil || !info.IsDir() {
+		t.Errorf("CopyTree() didn't copy the empty directory: %v", err)
+	}
+	if runtime.GOOS != "windows" {
+		if info, err := os.Stat(filepath.Join(dst, "sub", "run.sh")); err != nil || info.Mode().Perm() != 0o755 {
+			t.Errorf("CopyTree() didn't preserve the permissions of run.sh: %v, %v", info, err)
+		}
+	}
+	if symlinks {
+		if link, err := os.Readlink(filepath.Join(dst, "link")); err != nil || link != "data.txt" {
+			t.Errorf("CopyTree() copied the symbolic link as %q, %v", link, err)
+		}
+	}
+
+	if err := CheckFixture(src); err != nil {
+		t.Errorf("CheckFixture() error = %v", err)
+	}
+	for _, fixture := range []string{filepath.Join(src, "data.txt"), filepath.Join(src, "missing")} {
+		if err := CheckFixture(fixture); !errors.Is(err, ErrInvalidFixture) {
+			t.Errorf("CheckFixture(%s) error = %v, want %v", fixture, err, ErrInvalidFixture)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package internal
+
+import "strings"
+
+// RepeatedFlagValues returns every value given to a (non-boolean) flag in the command-line
+// arguments, in order. commando only keeps the last value of a flag which is given multiple
+// times, so flags that can be repeated are read from the raw arguments with this function.
+// `names` are the long and short names of the flag, without the leading dashes.
+func RepeatedFlagValues(args []string, names ...string) []string {
+	isFlagName := func(arg string) bool {
+		for _, name := range names {
+			if (len(name) == 1 && arg == "-"+name) || (len(name) > 1 && arg == "--"+name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var values []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if flag, value, found := strings.Cut(arg, "="); found && strings.HasPrefix(flag, "-") && isFlagName(flag) {
+			values = append(values, value)
+			continue
+		}
+		if !isFlagName(arg) {
+			continue
+		}
+		// just like commando, the value is the next argument unless that is a flag itself
+		if i+1 < len(args) && args[i+1] != "" && !isFlag(args[i+1]) {
+			values = append(values, args[i+1])
+			i++
+		}
+	}
+	return values
+}
+
+func isFlag(arg string) bool {
+	return len(arg) >= 2 && strings.HasPrefix(arg, "-")
+}
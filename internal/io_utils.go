@@ -2,13 +2,18 @@ package internal
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"math"
 	"os"
 	"os/user"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
+var ErrInvalidByteSize = errors.New("invalid byte size")
+
 // formats the text in a javascript like syntax.
 func format(text string, params map[string]string) string {
 	for key, val := range params {
@@ -52,9 +57,11 @@ func writeToFile(text, filename string) (err error) {
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-
 	_, err = f.WriteString(text)
+	// the data may only fail to reach the file when it's closed
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
 	return err
 }
 
@@ -102,3 +109,44 @@ func addExtension(filename, ext string) string {
 	}
 	return filename
 }
+
+// ParseByteSize parses a number of bytes with an optional binary unit suffix,
+// one of K, M, G (case insensitive, optionally followed by B or iB), e.g. 512, 64K, 10MiB, 1GB.
+func ParseByteSize(sizeString string) (int64, error) {
+	sizeString = strings.ToUpper(strings.TrimSpace(sizeString))
+	sizeString = strings.TrimSuffix(strings.TrimSuffix(sizeString, "B"), "I")
+	multiplier := int64(1)
+	if sizeString != "" {
+		switch sizeString[len(sizeString)-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		}
+	}
+	if multiplier != 1 {
+		sizeString = sizeString[:len(sizeString)-1]
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(sizeString), 10, 64)
+	if err != nil || size < 0 {
+		return 0, ErrInvalidByteSize
+	}
+	return size * multiplier, nil
+}
+
+// FormatBytes formats the given number of bytes with a binary unit, e.g. 1.50 MiB.
+func FormatBytes[T numberLike](bytes T) string {
+	value := float64(bytes)
+	units := []string{"B", "KiB", "MiB", "GiB"}
+	unit := 0
+	for math.Abs(value) >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%.0f %s", value, units[unit])
+	}
+	return fmt.Sprintf("%.2f %s", value, units[unit])
+}
@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrInvalidMetrics = errors.New("invalid metrics")
+
+// RusageMetrics is the name of the optional metrics read from the rusage of every run.
+const RusageMetrics = "rusage"
+
+// the optional per-run metrics which can be enabled with the --metrics flag
+var availableMetrics = []string{RusageMetrics}
+
+// ParseMetrics parses the value of the --metrics flag, a comma separated list of optional metrics
+// to collect for every run, and returns the set of enabled metrics.
+func ParseMetrics(spec string) (map[string]bool, error) {
+	metrics := map[string]bool{}
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(strings.ToLower(name))
+		if name == "" {
+			continue
+		}
+		if !slices.Contains(availableMetrics, name) {
+			return nil, fmt.Errorf("%w: unknown metric `%s`, available metrics are: %s", ErrInvalidMetrics, name, strings.Join(availableMetrics, ", "))
+		}
+		metrics[name] = true
+	}
+	return metrics, nil
+}
+
+// ResourceUsage holds the counters of the rusage of a run, or their averages over all the runs.
+// They explain the variance that the timings alone hide, e.g. a run which got preempted or hit the disk.
+type ResourceUsage struct {
+	VoluntaryContextSwitches   float64 `json:"voluntary_context_switches"`
+	InvoluntaryContextSwitches float64 `json:"involuntary_context_switches"`
+	MinorPageFaults            float64 `json:"minor_page_faults"`
+	MajorPageFaults            float64 `json:"major_page_faults"`
+	BlockInputs                float64 `json:"block_inputs"`
+	BlockOutputs               float64 `json:"block_outputs"`
+}
+
+// the names of the csv columns of the average resource usage, in the order of [ResourceUsage.values]
+var resourceUsageColumns = []string{
+	"voluntary_context_switches",
+	"involuntary_context_switches",
+	"minor_page_faults",
+	"major_page_faults",
+	"block_inputs",
+	"block_outputs",
+}
+
+func (ru *ResourceUsage) values() []float64 {
+	return []float64{
+		ru.VoluntaryContextSwitches,
+		ru.InvoluntaryContextSwitches,
+		ru.MinorPageFaults,
+		ru.MajorPageFaults,
+		ru.BlockInputs,
+		ru.BlockOutputs,
+	}
+}
+
+// AverageResourceUsage returns the average of every counter over the given runs.
+func AverageResourceUsage(usages []ResourceUsage) *ResourceUsage {
+	if len(usages) == 0 {
+		return nil
+	}
+	var average ResourceUsage
+	for _, u := range usages {
+		average.VoluntaryContextSwitches += u.VoluntaryContextSwitches
+		average.InvoluntaryContextSwitches += u.InvoluntaryContextSwitches
+		average.MinorPageFaults += u.MinorPageFaults
+		average.MajorPageFaults += u.MajorPageFaults
+		average.BlockInputs += u.BlockInputs
+		average.BlockOutputs += u.BlockOutputs
+	}
+	n := float64(len(usages))
+	average.VoluntaryContextSwitches /= n
+	average.InvoluntaryContextSwitches /= n
+	average.MinorPageFaults /= n
+	average.MajorPageFaults /= n
+	average.BlockInputs /= n
+	average.BlockOutputs /= n
+	return &average
+}
+
+// PerfCounter holds the statistics of a perf counter over all the runs of a command.
+type PerfCounter struct {
+	Name   string    `json:"name"`
+	Mean   float64   `json:"mean"`
+	Stddev float64   `json:"stddev"`
+	Min    float64   `json:"min"`
+	Max    float64   `json:"max"`
+	Values []float64 `json:"values"`
+}
+
+// AggregatePerfCounters computes the statistics of every named counter from the values of all the runs.
+func AggregatePerfCounters(names []string, runs []map[string]float64) []PerfCounter {
+	if len(runs) == 0 {
+		return nil
+	}
+	counters := make([]PerfCounter, len(names))
+	for i, name := range names {
+		values := MapFunc[[]map[string]float64, []float64](func(run map[string]float64) float64 { return run[name] }, runs)
+		mean := CalculateAverage(values)
+		counters[i] = PerfCounter{
+			Name:   name,
+			Mean:   mean,
+			Stddev: CalculateStandardDeviation(values, mean),
+			Min:    slices.Min(values),
+			Max:    slices.Max(values),
+			Values: values,
+		}
+	}
+	return counters
+}
+
+// String formats the mean and standard deviation of the counter, the clocks which count
+// nanoseconds are formatted as durations.
+func (pc PerfCounter) String() string {
+	if pc.Name == "task-clock" || pc.Name == "cpu-clock" {
+		return DurationFromNumber(pc.Mean, time.Nanosecond).String() + " ± " + DurationFromNumber(pc.Stddev, time.Nanosecond).String()
+	}
+	return fmt.Sprintf("%.0f ± %.0f", pc.Mean, pc.Stddev)
+}
+
+// perfCounterNames returns the names of the perf counters of all the results, in the order they were measured.
+func perfCounterNames(results []*SpeedResult) []string {
+	var names []string
+	for _, r := range results {
+		for _, pc := range r.PerfCounters {
+			if !slices.Contains(names, pc.Name) {
+				names = append(names, pc.Name)
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMetrics(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    map[string]bool
+		wantErr bool
+	}{
+		{"empty", "", map[string]bool{}, false},
+		{"rusage", "rusage", map[string]bool{"rusage": true}, false},
+		{"case and spaces", " RUsage , ", map[string]bool{"rusage": true}, false},
+		{"unknown", "rusage,perf", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMetrics(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMetrics() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseMetrics() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAverageResourceUsage(t *testing.T) {
+	usages := []ResourceUsage{
+		{VoluntaryContextSwitches: 1, MinorPageFaults: 10, BlockOutputs: 4},
+		{VoluntaryContextSwitches: 3, InvoluntaryContextSwitches: 2, MinorPageFaults: 20},
+	}
+	want := &ResourceUsage{VoluntaryContextSwitches: 2, InvoluntaryContextSwitches: 1, MinorPageFaults: 15, BlockOutputs: 2}
+	if got := AverageResourceUsage(usages); !reflect.DeepEqual(got, want) {
+		t.Errorf("AverageResourceUsage() = %v, want %v", got, want)
+	}
+	if got := AverageResourceUsage(nil); got != nil {
+		t.Errorf("AverageResourceUsage(nil) = %v, want nil", got)
+	}
+}
+
+func TestAggregatePerfCounters(t *testing.T) {
+	runs := []map[string]float64{
+		{"instructions": 100, "task-clock": 2000},
+		{"instructions": 300, "task-clock": 4000},
+	}
+	got := AggregatePerfCounters([]string{"instructions", "task-clock"}, runs)
+	want := []PerfCounter{
+		{Name: "instructions", Mean: 200, Stddev: CalculateStandardDeviation([]float64{100, 300}, 200), Min: 100, Max: 300, Values: []float64{100, 300}},
+		{Name: "task-clock", Mean: 3000, Stddev: CalculateStandardDeviation([]float64{2000, 4000}, 3000), Min: 2000, Max: 4000, Values: []float64{2000, 4000}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AggregatePerfCounters() = %v, want %v", got, want)
+	}
+	if got := AggregatePerfCounters([]string{"instructions"}, nil); got != nil {
+		t.Errorf("AggregatePerfCounters() without runs = %v, want nil", got)
+	}
+}
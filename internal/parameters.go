@@ -0,0 +1,242 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidParameterScan = errors.New("invalid parameter scan")
+
+// maximum number of values a single numeric range can expand to,
+// guards against typos like 0:1e9
+const maxParameterValues = 100_000
+
+// maximum number of combinations the cross product of all the scanned variables can have
+const maxParameterCombinations = 100_000
+
+// Parameter represents a single scanned variable and all the values it takes.
+type Parameter struct {
+	Name   string
+	Values []string
+}
+
+// ParseParameterScan parses the value of the --parameter-scan flag.
+// The spec is a semicolon separated list of variables, each of which is either a numeric
+// range `name=start:end[:step]` or a list of values `name=[val1,val2,val3]`.
+func ParseParameterScan(spec string) ([]Parameter, error) {
+	var params []Parameter
+	seen := map[string]bool{}
+	for _, part := range strings.Split(spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, valueSpec, found := strings.Cut(part, "=")
+		name = strings.TrimSpace(name)
+		valueSpec = strings.TrimSpace(valueSpec)
+		if !found || name == "" || valueSpec == "" {
+			return nil, fmt.Errorf("%w: expected `name=start:end:step` or `name=[values]`, got `%s`", ErrInvalidParameterScan, part)
+		}
+		if strings.ContainsAny(name, "{} ") {
+			return nil, fmt.Errorf("%w: invalid variable name `%s`", ErrInvalidParameterScan, name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%w: variable `%s` is given more than once", ErrInvalidParameterScan, name)
+		}
+		seen[name] = true
+
+		var values []string
+		var err error
+		if strings.HasPrefix(valueSpec, "[") && strings.HasSuffix(valueSpec, "]") {
+			values, err = parseParameterList(valueSpec[1 : len(valueSpec)-1])
+		} else {
+			values, err = parseParameterRange(valueSpec)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("variable `%s`: %w", name, err)
+		}
+		params = append(params, Parameter{Name: name, Values: values})
+	}
+	if len(params) == 0 {
+		return nil, fmt.Errorf("%w: no variables given", ErrInvalidParameterScan)
+	}
+	return params, nil
+}
+
+func parseParameterList(list string) ([]string, error) {
+	var values []string
+	for _, v := range strings.Split(list, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			return nil, fmt.Errorf("%w: empty value in list `[%s]`", ErrInvalidParameterScan, list)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func parseParameterRange(rangeSpec string) ([]string, error) {
+	parts := strings.Split(rangeSpec, ":")
+	if len(parts) != 2 && len(parts) != 3 {
+		return nil, fmt.Errorf("%w: expected `start:end` or `start:end:step`, got `%s`", ErrInvalidParameterScan, rangeSpec)
+	}
+	if len(parts) == 2 {
+		parts = append(parts, "1")
+	}
+	numbers := make([]float64, 3)
+	for i, p := range parts {
+		n, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+			return nil, fmt.Errorf("%w: `%s` is not a number", ErrInvalidParameterScan, p)
+		}
+		numbers[i] = n
+	}
+	start, end, step := numbers[0], numbers[1], numbers[2]
+	if step == 0 || (end-start)/step < 0 {
+		return nil, fmt.Errorf("%w: the step %v never reaches %v from %v", ErrInvalidParameterScan, step, end, start)
+	}
+
+	// the number of steps is compared before the conversion, which overflows for huge ranges
+	steps := math.Floor((end-start)/step + 1e-9)
+	if steps >= maxParameterValues {
+		return nil, fmt.Errorf("%w: the range `%s` has more than %d values", ErrInvalidParameterScan, rangeSpec, maxParameterValues)
+	}
+	// computing every value from the start avoids accumulating floating point errors
+	values := make([]string, int(steps)+1)
+	for i := range values {
+		values[i] = formatParameterNumber(start + float64(i)*step)
+	}
+	return values, nil
+}
+
+func formatParameterNumber(n float64) string {
+	// round away the noise like 0.30000000000000004
+	return strconv.FormatFloat(roundFloat(n, 9), 'f', -1, 64)
+}
+
+// ExpandParameters returns every combination of the given parameters' values (the cartesian
+// product), in order, with the last parameter varying the fastest. It fails if there are more
+// than [maxParameterCombinations] combinations.
+func ExpandParameters(params []Parameter) ([]map[string]string, error) {
+	total := 1
+	for _, param := range params {
+		// every variable has at most maxParameterValues values, so this can't overflow
+		total *= len(param.Values)
+		if total > maxParameterCombinations {
+			return nil, fmt.Errorf("%w: the variables have more than %d combinations", ErrInvalidParameterScan, maxParameterCombinations)
+		}
+	}
+
+	combinations := []map[string]string{{}}
+	for _, param := range params {
+		var expanded []map[string]string
+		for _, combination := range combinations {
+			for _, value := range param.Values {
+				next := make(map[string]string, len(combination)+1)
+				for k, v := range combination {
+					next[k] = v
+				}
+				next[param.Name] = value
+				expanded = append(expanded, next)
+			}
+		}
+		combinations = expanded
+	}
+	return combinations, nil
+}
+
+// SubstituteParameters replaces every `{variable}` placeholder in text with the variable's value.
+func SubstituteParameters(text string, values map[string]string) string {
+	if len(values) == 0 {
+		return text
+	}
+	// a single replacer makes sure that values containing placeholders aren't substituted again
+	oldNew := make([]string, 0, 2*len(values))
+	for name, value := range values {
+		oldNew = append(oldNew, "{"+name+"}", value)
+	}
+	return strings.NewReplacer(oldNew...).Replace(text)
+}
+
+// FormatParameters formats the given parameter values as `name=value` pairs, sorted by name.
+func FormatParameters(values map[string]string) string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := MapFunc[[]string, []string](func(name string) string { return name + "=" + values[name] }, names)
+	return strings.Join(pairs, ", ")
+}
+
+// parameterNames returns the sorted union of the parameter names of all the results.
+func parameterNames(results []*SpeedResult) []string {
+	var names []string
+	for _, r := range results {
+		for name := range r.Parameters {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseParameterRules parses the value of the --parameter-include and --parameter-exclude flags.
+// Rules are separated by semicolons, and every rule is a comma separated list of `name=value`
+// conditions, all of which must hold for a combination to match the rule.
+// Every variable in the rules must be one of the scanned parameters.
+func ParseParameterRules(spec string, params []Parameter) ([]map[string]string, error) {
+	var rules []map[string]string
+	for _, ruleSpec := range strings.Split(spec, ";") {
+		ruleSpec = strings.TrimSpace(ruleSpec)
+		if ruleSpec == "" {
+			continue
+		}
+		rule := map[string]string{}
+		for _, condition := range strings.Split(ruleSpec, ",") {
+			name, value, found := strings.Cut(condition, "=")
+			name = strings.TrimSpace(name)
+			value = strings.TrimSpace(value)
+			if !found || name == "" || value == "" {
+				return nil, fmt.Errorf("%w: expected `name=value` conditions, got `%s`", ErrInvalidParameterScan, condition)
+			}
+			if !slices.ContainsFunc(params, func(p Parameter) bool { return p.Name == name }) {
+				return nil, fmt.Errorf("%w: `%s` is not a scanned variable", ErrInvalidParameterScan, name)
+			}
+			rule[name] = value
+		}
+		rules = append(rules, rule)
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("%w: no rules given", ErrInvalidParameterScan)
+	}
+	return rules, nil
+}
+
+// returns true if the combination satisfies every condition of the rule
+func matchesRule(values, rule map[string]string) bool {
+	for name, value := range rule {
+		if values[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// FilterParameterCombinations keeps only those combinations which match at least one of the
+// include rules (if there are any) and none of the exclude rules.
+func FilterParameterCombinations(combinations []map[string]string, include, exclude []map[string]string) []map[string]string {
+	return FilterFunc(func(values map[string]string) bool {
+		if len(include) != 0 && !slices.ContainsFunc(include, func(rule map[string]string) bool { return matchesRule(values, rule) }) {
+			return false
+		}
+		return !slices.ContainsFunc(exclude, func(rule map[string]string) bool { return matchesRule(values, rule) })
+	}, combinations)
+}
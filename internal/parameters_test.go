@@ -0,0 +1,152 @@
+package internal
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestParseParameterScan(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    []Parameter
+		wantErr bool
+	}{
+		{"range", "threads=1:4", []Parameter{{"threads", []string{"1", "2", "3", "4"}}}, false},
+		{"range with step", "n=0:10:5", []Parameter{{"n", []string{"0", "5", "10"}}}, false},
+		{"float range", "x=0:0.3:0.1", []Parameter{{"x", []string{"0", "0.1", "0.2", "0.3"}}}, false},
+		{"descending range", "n=3:1:-1", []Parameter{{"n", []string{"3", "2", "1"}}}, false},
+		{"list", "mode=[fast, slow]", []Parameter{{"mode", []string{"fast", "slow"}}}, false},
+		{
+			"multiple variables",
+			"variable=1:2:1;var2=[val1,val2,val3]",
+			[]Parameter{{"variable", []string{"1", "2"}}, {"var2", []string{"val1", "val2", "val3"}}},
+			false,
+		},
+		{"missing value", "threads=", nil, true},
+		{"missing equals", "threads", nil, true},
+		{"zero step", "n=1:5:0", nil, true},
+		{"wrong direction", "n=1:5:-1", nil, true},
+		{"not a number", "n=a:5", nil, true},
+		{"empty list value", "mode=[fast,,slow]", nil, true},
+		{"duplicate variable", "n=1:2;n=[3]", nil, true},
+		{"empty", " ; ", nil, true},
+		{"too many values", "n=1:100001", nil, true},
+		{"huge range", "n=0:1e300", nil, true},
+		{"huge range with a tiny step", "n=0:1:1e-300", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseParameterScan(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseParameterScan() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseParameterScan() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandParameters(t *testing.T) {
+	params := []Parameter{{"a", []string{"1", "2"}}, {"b", []string{"x", "y"}}}
+	want := []map[string]string{
+		{"a": "1", "b": "x"},
+		{"a": "1", "b": "y"},
+		{"a": "2", "b": "x"},
+		{"a": "2", "b": "y"},
+	}
+	if got, err := ExpandParameters(params); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ExpandParameters() = %v, %v, want %v", got, err, want)
+	}
+}
+
+func TestExpandParametersLimit(t *testing.T) {
+	values := func(n int) []string {
+		v := make([]string, n)
+		for i := range v {
+			v[i] = strconv.Itoa(i)
+		}
+		return v
+	}
+	tests := []struct {
+		name    string
+		params  []Parameter
+		want    int
+		wantErr bool
+	}{
+		{"at the limit", []Parameter{{"a", values(1000)}, {"b", values(100)}}, maxParameterCombinations, false},
+		{"over the limit", []Parameter{{"a", values(1000)}, {"b", values(101)}}, 0, true},
+		{"over the limit with few values each", []Parameter{{"a", values(10)}, {"b", values(10)}, {"c", values(10)}, {"d", values(10)}, {"e", values(10)}, {"f", values(10)}}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandParameters(tt.params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExpandParameters() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != tt.want {
+				t.Errorf("ExpandParameters() has %d combinations, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestSubstituteParameters(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		values map[string]string
+		want   string
+	}{
+		{"no values", "sleep {n}", nil, "sleep {n}"},
+		{"single", "sleep {n}", map[string]string{"n": "1"}, "sleep 1"},
+		{"repeated", "{n}-{n}", map[string]string{"n": "1"}, "1-1"},
+		{"value with placeholder", "{a} {b}", map[string]string{"a": "{b}", "b": "2"}, "{b} 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SubstituteParameters(tt.text, tt.values); got != tt.want {
+				t.Errorf("SubstituteParameters() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterParameterCombinations(t *testing.T) {
+	params := []Parameter{{"a", []string{"1", "2"}}, {"b", []string{"x", "y"}}}
+	combinations, err := ExpandParameters(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parse := func(spec string) []map[string]string {
+		rules, err := ParseParameterRules(spec, params)
+		if err != nil {
+			t.Fatalf("ParseParameterRules(%s) error = %v", spec, err)
+		}
+		return rules
+	}
+	tests := []struct {
+		name    string
+		include []map[string]string
+		exclude []map[string]string
+		want    []map[string]string
+	}{
+		{"no rules", nil, nil, combinations},
+		{"include", parse("a=1"), nil, []map[string]string{{"a": "1", "b": "x"}, {"a": "1", "b": "y"}}},
+		{"exclude", nil, parse("a=1,b=x;b=y"), []map[string]string{{"a": "2", "b": "x"}}},
+		{"include and exclude", parse("a=2;b=x"), parse("a=2,b=x"), []map[string]string{{"a": "1", "b": "x"}, {"a": "2", "b": "y"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FilterParameterCombinations(combinations, tt.include, tt.exclude); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FilterParameterCombinations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ParseParameterRules("c=1", params); err == nil {
+		t.Errorf("ParseParameterRules() accepted an unknown variable")
+	}
+}
@@ -0,0 +1,225 @@
+package internal
+
+import (
+	"encoding/csv"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mitchellh/colorstring"
+)
+
+// PivotTable arranges the results of a parameter scan in matrices, with the values of one
+// scanned variable on the rows and the values of another one on the columns.
+type PivotTable struct {
+	RowAxis    string
+	ColumnAxis string
+	params     []Parameter
+	groups     []*pivotGroup
+}
+
+// every given command and combination of the remaining variables gets a matrix of its own
+type pivotGroup struct {
+	command string
+	fixed   map[string]string
+	cells   map[[2]string]*SpeedResult
+}
+
+func (g *pivotGroup) title() string {
+	if len(g.fixed) == 0 {
+		return g.command
+	}
+	return g.command + " (" + FormatParameters(g.fixed) + ")"
+}
+
+// ParsePivotAxes parses the value of the --pivot flag, which is of the form `row,column` where
+// both row and column are names of the scanned variables.
+func ParsePivotAxes(spec string, params []Parameter) (string, string, error) {
+	row, column, found := strings.Cut(spec, ",")
+	row = strings.TrimSpace(row)
+	column = strings.TrimSpace(column)
+	if !found || row == "" || column == "" {
+		return "", "", fmt.Errorf("%w: expected the pivot axes as `row,column`, got `%s`", ErrInvalidParameterScan, spec)
+	}
+	if row == column {
+		return "", "", fmt.Errorf("%w: the pivot axes must be two different variables", ErrInvalidParameterScan)
+	}
+	for _, axis := range []string{row, column} {
+		if !slices.ContainsFunc(params, func(p Parameter) bool { return p.Name == axis }) {
+			return "", "", fmt.Errorf("%w: `%s` is not a scanned variable", ErrInvalidParameterScan, axis)
+		}
+	}
+	return row, column, nil
+}
+
+// NewPivotTable returns an empty pivot table over the given scanned parameters.
+func NewPivotTable(params []Parameter, rowAxis, columnAxis string) *PivotTable {
+	return &PivotTable{RowAxis: rowAxis, ColumnAxis: columnAxis, params: params}
+}
+
+// Add places the result in the matrix of the command it was expanded from.
+// `command` must be the command as it was given, before the placeholders were substituted.
+func (pt *PivotTable) Add(command string, result *SpeedResult) {
+	fixed := map[string]string{}
+	for name, value := range result.Parameters {
+		if name != pt.RowAxis && name != pt.ColumnAxis {
+			fixed[name] = value
+		}
+	}
+	index := slices.IndexFunc(pt.groups, func(g *pivotGroup) bool {
+		return g.command == command && matchesRule(fixed, g.fixed)
+	})
+	if index < 0 {
+		pt.groups = append(pt.groups, &pivotGroup{command: command, fixed: fixed, cells: map[[2]string]*SpeedResult{}})
+		index = len(pt.groups) - 1
+	}
+	key := [2]string{result.Parameters[pt.RowAxis], result.Parameters[pt.ColumnAxis]}
+	pt.groups[index].cells[key] = result
+}
+
+// returns the values of the given axis which have at least one result, in the order they were scanned
+func (pt *PivotTable) axisValues(axis string) []string {
+	index := slices.IndexFunc(pt.params, func(p Parameter) bool { return p.Name == axis })
+	if index < 0 {
+		return nil
+	}
+	return FilterFunc(func(value string) bool {
+		for _, g := range pt.groups {
+			for key := range g.cells {
+				if (axis == pt.RowAxis && key[0] == value) || (axis == pt.ColumnAxis && key[1] == value) {
+					return true
+				}
+			}
+		}
+		return false
+	}, pt.params[index].Values)
+}
+
+// returns every matrix as rows of cells, the first row and the first column being the headers
+func (pt *PivotTable) matrices(cell func(*SpeedResult) string) [][][]string {
+	rows := pt.axisValues(pt.RowAxis)
+	columns := pt.axisValues(pt.ColumnAxis)
+	matrices := make([][][]string, len(pt.groups))
+	for i, g := range pt.groups {
+		header := append([]string{pt.RowAxis + " \\ " + pt.ColumnAxis}, columns...)
+		matrix := [][]string{header}
+		for _, row := range rows {
+			line := []string{row}
+			for _, column := range columns {
+				if r, ok := g.cells[[2]string{row, column}]; ok {
+					line = append(line, cell(r))
+				} else {
+					line = append(line, "-")
+				}
+			}
+			matrix = append(matrix, line)
+		}
+		matrices[i] = matrix
+	}
+	return matrices
+}
+
+// colors like the colorstring package, without resetting the colors at the end of the colored string
+var colorCodes = &colorstring.Colorize{Colors: colorstring.DefaultColors}
+
+// returns the text in the colors of the colorstring codes, followed by a reset. Unlike the text given to
+// colorstring, the text isn't searched for codes, so that square brackets in commands are printed as they are.
+func colorText(codes, text string) string {
+	return colorCodes.Color(codes) + text + colorCodes.Color("[reset]")
+}
+
+// PrintPivotTable prints the mean time of every result in the matrices, along with its relative mean
+// if the relative summary has been computed. The results are expected to be in microseconds.
+func PrintPivotTable(pt *PivotTable) {
+	matrices := pt.matrices(func(r *SpeedResult) string {
+		text := DurationFromNumber(r.AverageElapsed, time.Microsecond).String() + " ± " + DurationFromNumber(r.StandardDeviation, time.Microsecond).String()
+		if r.RelativeMean != 0 {
+			text += fmt.Sprintf(" (%.2f)", r.RelativeMean)
+		}
+		return text
+	})
+	for i, matrix := range matrices {
+		fmt.Println()
+		fmt.Println(colorText("[bold][white]", pt.groups[i].title()))
+		widths := make([]int, len(matrix[0]))
+		for _, line := range matrix {
+			for j, cell := range line {
+				widths[j] = max(widths[j], utf8.RuneCountInString(cell))
+			}
+		}
+		for j, line := range matrix {
+			var bobTheBuilder strings.Builder
+			for k, cell := range line {
+				padded := cell + strings.Repeat(" ", widths[k]-utf8.RuneCountInString(cell))
+				switch {
+				case j == 0 || k == 0:
+					bobTheBuilder.WriteString("  " + colorText("[yellow]", padded))
+				default:
+					bobTheBuilder.WriteString("  " + colorText("[green]", padded))
+				}
+			}
+			fmt.Println(bobTheBuilder.String())
+		}
+	}
+}
+
+// appends the pivot matrices to the markdown summary
+func markdownPivot(pt *PivotTable, timeUnit string) string {
+	matrices := pt.matrices(func(r *SpeedResult) string {
+		return fmt.Sprintf("%.2f ± %.2f", r.AverageElapsed, r.StandardDeviation)
+	})
+	text := ""
+	for i, matrix := range matrices {
+		text += fmt.Sprintf("\n## `%s` [%s]\n\n", pt.groups[i].title(), timeUnit)
+		for j, line := range matrix {
+			text += "| " + strings.Join(line, " | ") + " |\n"
+			if j == 0 {
+				text += strings.Repeat("| --- ", len(line)) + "|\n"
+			}
+		}
+	}
+	return text
+}
+
+// csvPivot writes all the pivot matrices to a single csv file, one line per command, row value and
+// combination of the remaining variables.
+func csvPivot(pt *PivotTable, filename string) error {
+	var fixedNames []string
+	for _, p := range pt.params {
+		if p.Name != pt.RowAxis && p.Name != pt.ColumnAxis {
+			fixedNames = append(fixedNames, p.Name)
+		}
+	}
+	matrices := pt.matrices(func(r *SpeedResult) string { return fmt.Sprintf("%f", r.AverageElapsed) })
+
+	// commands and values may contain commas and quotes, which the csv writer quotes
+	var text strings.Builder
+	w := csv.NewWriter(&text)
+	header := append([]string{"command"}, fixedNames...)
+	if len(matrices) != 0 {
+		header = append(header, matrices[0][0]...)
+	}
+	w.Write(header)
+	for i, matrix := range matrices {
+		g := pt.groups[i]
+		prefix := []string{g.command}
+		for _, name := range fixedNames {
+			prefix = append(prefix, g.fixed[name])
+		}
+		for _, line := range matrix[1:] {
+			w.Write(append(slices.Clip(prefix), line...))
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	if err := writeToFile(text.String(), filename); err != nil {
+		return err
+	}
+	logWritten("pivot table", filename)
+	return nil
+}
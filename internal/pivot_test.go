@@ -0,0 +1,200 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// returns a result of the command expanded with the given parameters
+func pivotResult(command string, mean float64, params ...string) *SpeedResult {
+	parameters := map[string]string{}
+	for i := 0; i+1 < len(params); i += 2 {
+		parameters[params[i]] = params[i+1]
+	}
+	return &SpeedResult{Command: command, Parameters: parameters, AverageElapsed: mean, StandardDeviation: mean / 10}
+}
+
+// a scan of two variables, the rows being the threads and the columns the sizes
+func twoParameterPivot() *PivotTable {
+	params := []Parameter{{Name: "threads", Values: []string{"1", "2"}}, {Name: "size", Values: []string{"1k", "1m"}}}
+	pt := NewPivotTable(params, "threads", "size")
+	// added out of the scan order, which the matrices follow regardless
+	pt.Add("zip -T {threads} {size}", pivotResult("zip -T 2 1m", 40, "threads", "2", "size", "1m"))
+	pt.Add("zip -T {threads} {size}", pivotResult("zip -T 1 1k", 10, "threads", "1", "size", "1k"))
+	pt.Add("zip -T {threads} {size}", pivotResult("zip -T 1 1m", 30, "threads", "1", "size", "1m"))
+	pt.Add("zip -T {threads} {size}", pivotResult("zip -T 2 1k", 20, "threads", "2", "size", "1k"))
+	return pt
+}
+
+// a scan of two variables, one of which has a single value
+func singleColumnPivot() *PivotTable {
+	params := []Parameter{{Name: "threads", Values: []string{"1", "2"}}, {Name: "size", Values: []string{"1k"}}}
+	pt := NewPivotTable(params, "threads", "size")
+	pt.Add("zip -T {threads} {size}", pivotResult("zip -T 1 1k", 10, "threads", "1", "size", "1k"))
+	pt.Add("zip -T {threads} {size}", pivotResult("zip -T 2 1k", 20, "threads", "2", "size", "1k"))
+	return pt
+}
+
+// a scan of three variables, every level getting a matrix of its own, with cells excluded from the scan
+func threeParameterPivot() *PivotTable {
+	params := []Parameter{
+		{Name: "level", Values: []string{"1", "9"}},
+		{Name: "threads", Values: []string{"1", "2", "4"}},
+		{Name: "size", Values: []string{"1k", "1m"}},
+	}
+	pt := NewPivotTable(params, "threads", "size")
+	pt.Add("zip -{level} -T {threads} {size}", pivotResult("zip -1 -T 1 1k", 1, "level", "1", "threads", "1", "size", "1k"))
+	pt.Add("zip -{level} -T {threads} {size}", pivotResult("zip -1 -T 2 1m", 2, "level", "1", "threads", "2", "size", "1m"))
+	pt.Add("zip -{level} -T {threads} {size}", pivotResult("zip -9 -T 1 1m", 3, "level", "9", "threads", "1", "size", "1m"))
+	// no result has 4 threads, so there's no row for it
+	return pt
+}
+
+// a scan of a command which contains a comma and quotes
+func quotedPivot() *PivotTable {
+	params := []Parameter{{Name: "threads", Values: []string{"1"}}, {Name: "size", Values: []string{"1k"}}}
+	pt := NewPivotTable(params, "threads", "size")
+	pt.Add(`cut -d, -f{threads} "{size}"`, pivotResult(`cut -d, -f1 "1k"`, 10, "threads", "1", "size", "1k"))
+	return pt
+}
+
+func TestParsePivotAxes(t *testing.T) {
+	params := []Parameter{{Name: "threads", Values: []string{"1"}}, {Name: "size", Values: []string{"1k"}}}
+	tests := []struct {
+		spec        string
+		params      []Parameter
+		row, column string
+		wantErr     bool
+	}{
+		{"threads,size", params, "threads", "size", false},
+		{" size , threads ", params, "size", "threads", false},
+		{"threads", params, "", "", true},
+		{"threads,threads", params, "", "", true},
+		{"threads,level", params, "", "", true},
+		// a scan of a single variable can't be pivoted
+		{"threads,size", params[:1], "", "", true},
+	}
+	for _, tt := range tests {
+		row, column, err := ParsePivotAxes(tt.spec, tt.params)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ParsePivotAxes(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+		}
+		if row != tt.row || column != tt.column {
+			t.Errorf("ParsePivotAxes(%q) = %q, %q, want %q, %q", tt.spec, row, column, tt.row, tt.column)
+		}
+	}
+}
+
+func TestPivotMatrices(t *testing.T) {
+	mean := func(r *SpeedResult) string { return r.Command }
+	tests := []struct {
+		name string
+		pt   *PivotTable
+		want [][][]string
+	}{
+		{"two parameters", twoParameterPivot(), [][][]string{{
+			{`threads \ size`, "1k", "1m"},
+			{"1", "zip -T 1 1k", "zip -T 1 1m"},
+			{"2", "zip -T 2 1k", "zip -T 2 1m"},
+		}}},
+		{"single column", singleColumnPivot(), [][][]string{{
+			{`threads \ size`, "1k"},
+			{"1", "zip -T 1 1k"},
+			{"2", "zip -T 2 1k"},
+		}}},
+		{"three parameters with missing cells", threeParameterPivot(), [][][]string{
+			{
+				{`threads \ size`, "1k", "1m"},
+				{"1", "zip -1 -T 1 1k", "-"},
+				{"2", "-", "zip -1 -T 2 1m"},
+			},
+			{
+				{`threads \ size`, "1k", "1m"},
+				{"1", "-", "zip -9 -T 1 1m"},
+				{"2", "-", "-"},
+			},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pt.matrices(mean); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("matrices() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkdownPivot(t *testing.T) {
+	tests := []struct {
+		name string
+		pt   *PivotTable
+		want string
+	}{
+		{"two parameters", twoParameterPivot(), "\n## `zip -T {threads} {size}` [ms]\n\n" +
+			"| threads \\ size | 1k | 1m |\n" +
+			"| --- | --- | --- |\n" +
+			"| 1 | 10.00 ± 1.00 | 30.00 ± 3.00 |\n" +
+			"| 2 | 20.00 ± 2.00 | 40.00 ± 4.00 |\n"},
+		{"three parameters with missing cells", threeParameterPivot(), "\n## `zip -{level} -T {threads} {size} (level=1)` [ms]\n\n" +
+			"| threads \\ size | 1k | 1m |\n" +
+			"| --- | --- | --- |\n" +
+			"| 1 | 1.00 ± 0.10 | - |\n" +
+			"| 2 | - | 2.00 ± 0.20 |\n" +
+			"\n## `zip -{level} -T {threads} {size} (level=9)` [ms]\n\n" +
+			"| threads \\ size | 1k | 1m |\n" +
+			"| --- | --- | --- |\n" +
+			"| 1 | - | 3.00 ± 0.30 |\n" +
+			"| 2 | - | - |\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := markdownPivot(tt.pt, "ms"); got != tt.want {
+				t.Errorf("markdownPivot() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSVPivot(t *testing.T) {
+	tests := []struct {
+		name string
+		pt   *PivotTable
+		want string
+	}{
+		{"two parameters", twoParameterPivot(), "command,threads \\ size,1k,1m\n" +
+			"zip -T {threads} {size},1,10.000000,30.000000\n" +
+			"zip -T {threads} {size},2,20.000000,40.000000\n"},
+		{"three parameters with missing cells", threeParameterPivot(), "command,level,threads \\ size,1k,1m\n" +
+			"zip -{level} -T {threads} {size},1,1,1.000000,-\n" +
+			"zip -{level} -T {threads} {size},1,2,-,2.000000\n" +
+			"zip -{level} -T {threads} {size},9,1,-,3.000000\n" +
+			"zip -{level} -T {threads} {size},9,2,-,-\n"},
+		{"quoted command", quotedPivot(), "command,threads \\ size,1k\n" +
+			"\"cut -d, -f{threads} \"\"{size}\"\"\",1,10.000000\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "pivot.csv")
+			if err := csvPivot(tt.pt, filename); err != nil {
+				t.Fatalf("csvPivot() error = %v", err)
+			}
+			got, err := os.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("csvPivot() wrote %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestColorText(t *testing.T) {
+	got := colorText("[bold][green]", "grep '[red]' [reset]")
+	want := "\033[1m\033[32mgrep '[red]' [reset]\033[0m"
+	if got != want {
+		t.Errorf("colorText() = %q, want %q", got, want)
+	}
+}
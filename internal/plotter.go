@@ -39,7 +39,7 @@ func histogram(results []*SpeedResult, timeUnit string) {
 		}
 		// h.Normalize(1)
 		h.FillColor = colors[i%len(colors)]
-		p.Legend.Add(result.Command, h)
+		p.Legend.Add(result.label(), h)
 		p.Add(h)
 	}
 	p.Legend.Top = true
@@ -67,7 +67,7 @@ func barPlot(results []*SpeedResult, timeUnit string) {
 
 	p.Add(bars)
 
-	p.NominalX(MapFunc[[]*SpeedResult, []string](func(r *SpeedResult) string { return r.Command }, results)...)
+	p.NominalX(MapFunc[[]*SpeedResult, []string](func(r *SpeedResult) string { return r.label() }, results)...)
 
 	barWidth := max(3, len(results))
 	if err := p.Save(font.Length(barWidth)*vg.Inch, 3*vg.Inch, "barchart.png"); err != nil {
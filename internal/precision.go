@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidPrecision = errors.New("invalid precision")
+
+// Statistic is the estimate of a command's run time whose confidence interval is narrowed down by the --precision flag.
+type Statistic string
+
+const (
+	MeanStatistic   Statistic = "mean"
+	MedianStatistic Statistic = "median"
+)
+
+// ParseStatistic parses the value of the --precision-stat flag.
+func ParseStatistic(spec string) (Statistic, error) {
+	switch s := Statistic(strings.ToLower(strings.TrimSpace(spec))); s {
+	case MeanStatistic, MedianStatistic:
+		return s, nil
+	default:
+		return "", fmt.Errorf("%w: unknown statistic `%s`, expected mean or median", ErrInvalidPrecision, spec)
+	}
+}
+
+// ParsePrecision parses the value of the --precision flag, a relative width given either as a
+// percentage like `1%` or as a fraction like `0.01`, and returns it as a fraction.
+func ParsePrecision(spec string) (float64, error) {
+	spec = strings.TrimSpace(spec)
+	number, percent := strings.CutSuffix(spec, "%")
+	precision, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: `%s` is not a number or a percentage", ErrInvalidPrecision, spec)
+	}
+	if percent {
+		precision /= 100
+	}
+	if !(precision > 0 && precision < 1) {
+		return 0, fmt.Errorf("%w: `%s` must be between 0%% and 100%%", ErrInvalidPrecision, spec)
+	}
+	return precision, nil
+}
+
+// the 97.5th percentile of Student's t-distribution for 1 to 30 degrees of freedom
+var studentT975 = []float64{
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+// the 97.5th percentile of the standard normal distribution
+const z975 = 1.959964
+
+// returns the critical value of a two-sided 95% interval with the given degrees of freedom
+func criticalT(df int) float64 {
+	if df <= len(studentT975) {
+		return studentT975[df-1]
+	}
+	// Cornish-Fisher expansion around the normal distribution, accurate to the third decimal past 30
+	n := float64(df)
+	return z975 + (math.Pow(z975, 3)+z975)/(4*n) + (5*math.Pow(z975, 5)+16*math.Pow(z975, 3)+3*z975)/(96*n*n)
+}
+
+// ConfidenceInterval returns the estimate of the given statistic of the data along with the bounds of its
+// 95% confidence interval. The interval of the mean is based on Student's t-distribution, while that of the
+// median is distribution-free and made of the order statistics around it, so that it isn't widened by outliers.
+// The bounds are infinite with less than two values. The data is left untouched.
+func ConfidenceInterval(data []float64, stat Statistic) (estimate, low, high float64) {
+	n := len(data)
+	if n == 0 {
+		return 0, math.Inf(-1), math.Inf(1)
+	}
+	if stat == MedianStatistic {
+		sorted := slices.Clone(data)
+		slices.Sort(sorted)
+		estimate = CalculatePercentile(sorted, 50)
+		if n < 2 {
+			return estimate, math.Inf(-1), math.Inf(1)
+		}
+		// 0-based ranks of the order statistics bounding the interval
+		spread := z975 * math.Sqrt(float64(n)) / 2
+		lower := max(int(math.Floor(float64(n)/2-spread)), 0)
+		upper := min(int(math.Ceil(float64(n)/2+spread)), n-1)
+		return estimate, sorted[lower], sorted[upper]
+	}
+
+	estimate = CalculateAverage(data)
+	if n < 2 {
+		return estimate, math.Inf(-1), math.Inf(1)
+	}
+	var deviationSum float64
+	for _, v := range data {
+		deviationSum += (v - estimate) * (v - estimate)
+	}
+	sampleStddev := math.Sqrt(deviationSum / float64(n-1))
+	halfWidth := criticalT(n-1) * sampleStddev / math.Sqrt(float64(n))
+	return estimate, estimate - halfWidth, estimate + halfWidth
+}
+
+// RelativePrecision returns the half-width of the 95% confidence interval of the given statistic of the data,
+// relative to the statistic itself, e.g. 0.01 when the statistic is known within ±1%.
+func RelativePrecision(data []float64, stat Statistic) float64 {
+	estimate, low, high := ConfidenceInterval(data, stat)
+	if estimate == 0 || math.IsInf(high-low, 0) {
+		return math.Inf(1)
+	}
+	return (high - low) / 2 / math.Abs(estimate)
+}
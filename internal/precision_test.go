@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParsePrecision(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    float64
+		wantErr bool
+	}{
+		{"1%", 0.01, false},
+		{" 2.5 %", 0.025, false},
+		{"0.05", 0.05, false},
+		{"0%", 0, true},
+		{"100%", 0, true},
+		{"-1%", 0, true},
+		{"one", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePrecision(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ParsePrecision(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+		}
+		if math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("ParsePrecision(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestRelativePrecision(t *testing.T) {
+	tests := []struct {
+		name string
+		data []float64
+		stat Statistic
+		want float64
+	}{
+		{"mean", []float64{9, 10, 11}, MeanStatistic, 4.303 / math.Sqrt(3) / 10},
+		{"mean of identical values", []float64{5, 5, 5}, MeanStatistic, 0},
+		{"mean of a single value", []float64{5}, MeanStatistic, math.Inf(1)},
+		{"median", []float64{10, 1, 2, 3, 4, 5, 6, 7, 8, 9}, MedianStatistic, (10 - 2) / 2 / 5.5},
+		{"median ignores outliers", []float64{5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 1000}, MedianStatistic, 0},
+		{"zero", []float64{0, 0}, MeanStatistic, math.Inf(1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RelativePrecision(tt.data, tt.stat); math.Abs(got-tt.want) > 1e-9 && !(math.IsInf(got, 1) && math.IsInf(tt.want, 1)) {
+				t.Errorf("RelativePrecision() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,10 +1,17 @@
 package internal
 
-import "time"
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
 
 // Contains all the numerical quantities (in microseconds) for relative speed comparison. Also used for export.
 type SpeedResult struct {
-	Command           string    `json:"command,omitempty"`
+	Command string `json:"command,omitempty"`
+	// the name given with --command-name, shown in place of the command in summaries
+	Name              string    `json:"name,omitempty"`
 	AverageElapsed    float64   `json:"mean,omitempty"`
 	AverageUser       float64   `json:"user,omitempty"`
 	AverageSystem     float64   `json:"system,omitempty"`
@@ -14,6 +21,60 @@ type SpeedResult struct {
 	Times             []float64 `json:"times,omitempty"`
 	RelativeMean      float64   `json:"relative_mean,omitempty"`
 	RelativeStddev    float64   `json:"relative_stddev,omitempty"`
+	// the values of the scanned variables the command was expanded with
+	Parameters map[string]string `json:"parameters,omitempty"`
+	// the environment variables set for the command, the whole environment if CleanEnv
+	Env      map[string]string `json:"env,omitempty"`
+	CleanEnv bool              `json:"clean_env,omitempty"`
+	Cwd      string            `json:"cwd,omitempty"`
+	// the fixture copied for every iteration
+	Isolate string `json:"isolate,omitempty"`
+	// executed around every run
+	Prepare string `json:"prepare,omitempty"`
+	Cleanup string `json:"cleanup,omitempty"`
+	// executed once before and after all the runs
+	Setup    string `json:"setup,omitempty"`
+	Conclude string `json:"conclude,omitempty"`
+	// the cpus the runs were restricted to, empty if they could run on any cpu
+	CPUs []int `json:"cpus,omitempty"`
+	// the number of concurrent runs and the runs completed per second, only set with more than one job
+	Jobs       int     `json:"jobs,omitempty"`
+	Throughput float64 `json:"throughput,omitempty"`
+	// null, pipe, inherit or the path of a file, the average bytes written being only counted with pipe
+	OutputMode  string  `json:"output_mode,omitempty"`
+	OutputBytes float64 `json:"output_bytes,omitempty"`
+	// the exit code of every run, -1 for the runs terminated by the signal at the same index in Signals
+	ExitCodes []int `json:"exit_codes,omitempty"`
+	// the non-zero exit codes given to --ignore-exit-codes, which don't fail the runs
+	AcceptedExitCodes []int    `json:"accepted_exit_codes,omitempty"`
+	Signals           []string `json:"signals,omitempty"`
+	// which runs were recorded with --on-timeout record, lasting the timeout
+	TimedOut []bool `json:"timed_out,omitempty"`
+	// the number of retried runs, and why each of the runs discarded within --max-failures failed
+	Retries   int      `json:"retries,omitempty"`
+	Discarded []string `json:"discarded,omitempty"`
+	// set when the benchmark was interrupted, the statistics only covering the completed runs
+	Partial bool `json:"partial,omitempty"`
+	// set when the runs were executed in a persistent shell, which doesn't tell their user and system times
+	PersistentShell bool `json:"persistent_shell,omitempty"`
+	// statistics of the peak memory usage (max RSS, in bytes) of the runs, zero where it isn't available
+	AverageMemory float64   `json:"mean_memory,omitempty"`
+	MemoryStddev  float64   `json:"memory_stddev,omitempty"`
+	MinMemory     float64   `json:"min_memory,omitempty"`
+	MaxMemory     float64   `json:"max_memory,omitempty"`
+	MemoryUsages  []float64 `json:"memory_usages,omitempty"`
+	// relative to the command using the least memory
+	RelativeMemory       float64 `json:"relative_memory,omitempty"`
+	RelativeMemoryStddev float64 `json:"relative_memory_stddev,omitempty"`
+	// the average rusage of the runs, only collected with --metrics rusage
+	ResourceUsage  *ResourceUsage  `json:"resource_usage,omitempty"`
+	ResourceUsages []ResourceUsage `json:"resource_usages,omitempty"`
+	PerfCounters   []PerfCounter   `json:"perf_counters,omitempty"`
+	// the relative half-width of the 95% confidence interval of the PrecisionStatistic of the run time,
+	// and the one requested with --precision
+	Precision          float64 `json:"precision,omitempty"`
+	PrecisionTarget    float64 `json:"precision_target,omitempty"`
+	PrecisionStatistic string  `json:"precision_statistic,omitempty"`
 }
 
 // PrintableResult struct which is shown at the end as benchmarking summary and is written to a file.
@@ -21,6 +82,7 @@ type SpeedResult struct {
 // durations, and time.Duration offers a .String() method.
 type PrintableResult struct {
 	Command           string
+	Name              string
 	Runs              int
 	AverageElapsed    string
 	AverageUser       string
@@ -28,6 +90,175 @@ type PrintableResult struct {
 	StandardDeviation string
 	Min               string
 	Max               string
+	Parameters        string
+	Failures          string
+	Accepted          string
+	Timeouts          string
+	Retries           int
+	Discarded         string
+	Partial           bool
+	AverageMemory     string
+	MinMemory         string
+	MaxMemory         string
+	ContextSwitches   string
+	PageFaults        string
+	BlockIO           string
+	PerfCounters      []PerfCounter
+	Jobs              int
+	Throughput        string
+	Latency           string
+	Precision         string
+}
+
+// DisplayName returns the name of the command if one was given, otherwise the command itself.
+func (sr *SpeedResult) DisplayName() string {
+	if sr.Name != "" {
+		return sr.Name
+	}
+	return sr.Command
+}
+
+// label returns the display name of the command, along with its parameter values if any, since a name
+// is usually shared by all the expansions of a command. Used wherever results need to be told apart from each other.
+func (sr *SpeedResult) label() string {
+	if len(sr.Parameters) == 0 {
+		return sr.DisplayName()
+	}
+	return sr.DisplayName() + " (" + FormatParameters(sr.Parameters) + ")"
+}
+
+// PrecisionMet tells whether the confidence interval was narrowed down to the requested precision.
+func (sr *SpeedResult) PrecisionMet() bool {
+	return sr.PrecisionTarget > 0 && sr.Precision > 0 && sr.Precision <= sr.PrecisionTarget
+}
+
+// FailedRuns returns the number of runs which exited with a non-zero exit code or were terminated by a signal,
+// except for the runs which timed out, counted by [SpeedResult.TimedOutRuns], and the runs which exited with
+// an accepted exit code, counted by [SpeedResult.AcceptedRuns].
+func (sr *SpeedResult) FailedRuns() int {
+	return len(FilterFunc(sr.failed, sr.runIndices()))
+}
+
+// AcceptedRuns returns the number of runs which exited with one of the `AcceptedExitCodes`.
+func (sr *SpeedResult) AcceptedRuns() int {
+	return len(FilterFunc(sr.accepted, sr.runIndices()))
+}
+
+// returns the indices of the runs whose exit codes were recorded
+func (sr *SpeedResult) runIndices() []int {
+	indices := make([]int, len(sr.ExitCodes))
+	for i := range indices {
+		indices[i] = i
+	}
+	return indices
+}
+
+// tells whether the i-th run exited with a non-zero exit code which was accepted
+func (sr *SpeedResult) accepted(i int) bool {
+	code := sr.ExitCodes[i]
+	return code != 0 && !sr.timedOut(i) && slices.Contains(sr.AcceptedExitCodes, code)
+}
+
+// tells whether the i-th run failed, i.e. it neither succeeded, timed out nor exited with an accepted exit code
+func (sr *SpeedResult) failed(i int) bool {
+	return sr.ExitCodes[i] != 0 && !sr.timedOut(i) && !sr.accepted(i)
+}
+
+// TimedOutRuns returns the number of runs which were stopped by the timeout and recorded as censored runs.
+func (sr *SpeedResult) TimedOutRuns() int {
+	return len(FilterFunc(func(timedOut bool) bool { return timedOut }, sr.TimedOut))
+}
+
+// tells whether the i-th run timed out
+func (sr *SpeedResult) timedOut(i int) bool {
+	return i < len(sr.TimedOut) && sr.TimedOut[i]
+}
+
+// returns the number of runs which timed out along with the timeout they were censored at,
+// given in the unit of the times, or an empty string if no run timed out
+func (sr *SpeedResult) timeoutSummary(timeUnit time.Duration) string {
+	timedOut := sr.TimedOutRuns()
+	if timedOut == 0 {
+		return ""
+	}
+	censoredAt := sr.Times[slices.Index(sr.TimedOut, true)]
+	return fmt.Sprintf("%d of %d (recorded as lasting the %s timeout, the statistics are lower bounds)", timedOut, len(sr.TimedOut), formatDuration(censoredAt, timeUnit))
+}
+
+// returns the number of failed runs along with a histogram of their exit codes and signals,
+// e.g. `3 of 20 (exit code 1 ×2, killed ×1)`, or an empty string if no run failed
+func (sr *SpeedResult) failureSummary() string {
+	return sr.exitSummary(sr.failed)
+}
+
+// returns the number of runs which exited with an accepted exit code along with a histogram of
+// their exit codes, e.g. `2 of 20 (exit code 3 ×2)`, or an empty string if there's none
+func (sr *SpeedResult) acceptedSummary() string {
+	return sr.exitSummary(sr.accepted)
+}
+
+// returns the number of runs which are included along with a histogram of their exit codes and signals
+func (sr *SpeedResult) exitSummary(include func(i int) bool) string {
+	runs := FilterFunc(include, sr.runIndices())
+	if len(runs) == 0 {
+		return ""
+	}
+	codes := map[int]int{}
+	signals := map[string]int{}
+	for _, i := range runs {
+		if i < len(sr.Signals) && sr.Signals[i] != "" {
+			signals[sr.Signals[i]]++
+		} else {
+			codes[sr.ExitCodes[i]]++
+		}
+	}
+
+	var histogram []string
+	sortedCodes := make([]int, 0, len(codes))
+	for code := range codes {
+		sortedCodes = append(sortedCodes, code)
+	}
+	slices.Sort(sortedCodes)
+	for _, code := range sortedCodes {
+		histogram = append(histogram, fmt.Sprintf("exit code %d ×%d", code, codes[code]))
+	}
+	sortedSignals := make([]string, 0, len(signals))
+	for signal := range signals {
+		sortedSignals = append(sortedSignals, signal)
+	}
+	slices.Sort(sortedSignals)
+	for _, signal := range sortedSignals {
+		histogram = append(histogram, fmt.Sprintf("%s ×%d", signal, signals[signal]))
+	}
+	return fmt.Sprintf("%d of %d (%s)", len(runs), len(sr.ExitCodes), strings.Join(histogram, ", "))
+}
+
+// returns the number of discarded runs along with a histogram of the reasons they failed,
+// e.g. `2 of 22 (exit status 1 ×2)`, or an empty string if no run was discarded
+func (sr *SpeedResult) discardSummary() string {
+	if len(sr.Discarded) == 0 {
+		return ""
+	}
+	reasons := map[string]int{}
+	for _, reason := range sr.Discarded {
+		reasons[reason]++
+	}
+	sorted := make([]string, 0, len(reasons))
+	for reason := range reasons {
+		sorted = append(sorted, reason)
+	}
+	slices.Sort(sorted)
+	histogram := make([]string, len(sorted))
+	for i, reason := range sorted {
+		histogram[i] = fmt.Sprintf("%s ×%d", reason, reasons[reason])
+	}
+	return fmt.Sprintf("%d of %d (%s)", len(sr.Discarded), len(sr.Times)+len(sr.Discarded), strings.Join(histogram, ", "))
+}
+
+// formatDuration formats a time given in the unit, going through microseconds so that no precision
+// is lost to the rounding of [DurationFromNumber]
+func formatDuration(t float64, unit time.Duration) string {
+	return DurationFromNumber(t*float64(unit)/float64(time.Microsecond), time.Microsecond).String()
 }
 
 func NewPrintableResult() *PrintableResult {
@@ -35,15 +266,58 @@ func NewPrintableResult() *PrintableResult {
 	return &pr
 }
 
-func (pr *PrintableResult) FromSpeedResult(sr SpeedResult) *PrintableResult {
+// FromSpeedResult formats the result, whose times are in the given unit (microseconds unless
+// converted by [ModifyTimeUnit]).
+func (pr *PrintableResult) FromSpeedResult(sr SpeedResult, timeUnit time.Duration) *PrintableResult {
 	pr.Command = sr.Command
+	pr.Name = sr.Name
 	pr.Runs = len(sr.Times)
-	pr.AverageElapsed = DurationFromNumber(sr.AverageElapsed, time.Microsecond).String()
-	pr.AverageUser = DurationFromNumber(sr.AverageUser, time.Microsecond).String()
-	pr.AverageSystem = DurationFromNumber(sr.AverageSystem, time.Microsecond).String()
-	pr.StandardDeviation = DurationFromNumber(sr.StandardDeviation, time.Microsecond).String()
-	pr.Max = DurationFromNumber(sr.Max, time.Microsecond).String()
-	pr.Min = DurationFromNumber(sr.Min, time.Microsecond).String()
+	pr.AverageElapsed = formatDuration(sr.AverageElapsed, timeUnit)
+	if !sr.PersistentShell {
+		pr.AverageUser = formatDuration(sr.AverageUser, timeUnit)
+		pr.AverageSystem = formatDuration(sr.AverageSystem, timeUnit)
+	}
+	pr.StandardDeviation = formatDuration(sr.StandardDeviation, timeUnit)
+	pr.Max = formatDuration(sr.Max, timeUnit)
+	pr.Min = formatDuration(sr.Min, timeUnit)
+	pr.Parameters = FormatParameters(sr.Parameters)
+	pr.Failures = sr.failureSummary()
+	pr.Accepted = sr.acceptedSummary()
+	pr.Timeouts = sr.timeoutSummary(timeUnit)
+	pr.Retries = sr.Retries
+	pr.Discarded = sr.discardSummary()
+	pr.Partial = sr.Partial
+	if sr.AverageMemory != 0 {
+		pr.AverageMemory = FormatBytes(sr.AverageMemory)
+		pr.MinMemory = FormatBytes(sr.MinMemory)
+		pr.MaxMemory = FormatBytes(sr.MaxMemory)
+	}
+	if ru := sr.ResourceUsage; ru != nil {
+		pr.ContextSwitches = fmt.Sprintf("%.1f voluntary, %.1f involuntary", ru.VoluntaryContextSwitches, ru.InvoluntaryContextSwitches)
+		pr.PageFaults = fmt.Sprintf("%.1f minor, %.1f major", ru.MinorPageFaults, ru.MajorPageFaults)
+		pr.BlockIO = fmt.Sprintf("%.1f in, %.1f out", ru.BlockInputs, ru.BlockOutputs)
+	}
+	pr.PerfCounters = sr.PerfCounters
+	if sr.PrecisionTarget > 0 {
+		met := "not met"
+		if sr.PrecisionMet() {
+			met = "met"
+		}
+		achieved := "unknown"
+		if sr.Precision > 0 {
+			achieved = fmt.Sprintf("± %.2f%%", sr.Precision*100)
+		}
+		pr.Precision = fmt.Sprintf("%s of the %s (target ± %.2f%% %s)", achieved, sr.PrecisionStatistic, sr.PrecisionTarget*100, met)
+	}
+	if sr.Jobs > 1 {
+		pr.Jobs = sr.Jobs
+		pr.Throughput = fmt.Sprintf("%.2f runs/s", sr.Throughput)
+		pr.Latency = fmt.Sprintf("p50: %s, p95: %s, p99: %s",
+			formatDuration(CalculatePercentile(sr.Times, 50), timeUnit),
+			formatDuration(CalculatePercentile(sr.Times, 95), timeUnit),
+			formatDuration(CalculatePercentile(sr.Times, 99), timeUnit),
+		)
+	}
 	return pr
 }
 
@@ -61,3 +335,18 @@ func (a ByAverage) Swap(i, j int) {
 func (a ByAverage) Less(i, j int) bool {
 	return a[i].AverageElapsed < a[j].AverageElapsed
 }
+
+// Implements [sort.Interface] for []Result based on the AverageMemory field.
+type ByMemory []*SpeedResult
+
+func (a ByMemory) Len() int {
+	return len(a)
+}
+
+func (a ByMemory) Swap(i, j int) {
+	a[i], a[j] = a[j], a[i]
+}
+
+func (a ByMemory) Less(i, j int) bool {
+	return a[i].AverageMemory < a[j].AverageMemory
+}
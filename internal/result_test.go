@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFailureSummary(t *testing.T) {
+	tests := []struct {
+		name   string
+		result SpeedResult
+		want   string
+	}{
+		{"no failures", SpeedResult{ExitCodes: []int{0, 0}}, ""},
+		{"exit codes", SpeedResult{ExitCodes: []int{2, 0, 1, 2}}, "3 of 4 (exit code 1 ×1, exit code 2 ×2)"},
+		{
+			"signals",
+			SpeedResult{ExitCodes: []int{-1, 1, -1, 0}, Signals: []string{"killed", "", "terminated", ""}},
+			"3 of 4 (exit code 1 ×1, killed ×1, terminated ×1)",
+		},
+		{"accepted exit codes", SpeedResult{ExitCodes: []int{3, 3, 1, 0}, AcceptedExitCodes: []int{3}}, "1 of 4 (exit code 1 ×1)"},
+		{"only accepted exit codes", SpeedResult{ExitCodes: []int{3, 3}, AcceptedExitCodes: []int{3}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.result.failureSummary(); got != tt.want {
+				t.Errorf("failureSummary() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAcceptedSummary(t *testing.T) {
+	tests := []struct {
+		name   string
+		result SpeedResult
+		want   string
+		runs   int
+	}{
+		{"none accepted", SpeedResult{ExitCodes: []int{0, 1}}, "", 0},
+		{"accepted exit codes", SpeedResult{ExitCodes: []int{3, 0, 3, 1}, AcceptedExitCodes: []int{1, 3}}, "3 of 4 (exit code 1 ×1, exit code 3 ×2)", 3},
+		{"signals are never accepted", SpeedResult{ExitCodes: []int{-1, 3}, Signals: []string{"killed", ""}, AcceptedExitCodes: []int{3}}, "1 of 2 (exit code 3 ×1)", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.result.acceptedSummary(); got != tt.want {
+				t.Errorf("acceptedSummary() = %v, want %v", got, tt.want)
+			}
+			if got := tt.result.AcceptedRuns(); got != tt.runs {
+				t.Errorf("AcceptedRuns() = %v, want %v", got, tt.runs)
+			}
+		})
+	}
+}
+
+func TestFromSpeedResultTimeUnit(t *testing.T) {
+	tests := []struct {
+		name     string
+		result   SpeedResult
+		timeUnit time.Duration
+	}{
+		{"microseconds", SpeedResult{AverageElapsed: 2000, Times: []float64{1000, 2000, 3000}, Jobs: 2}, time.Microsecond},
+		// as converted by ModifyTimeUnit for the exports
+		{"milliseconds", SpeedResult{AverageElapsed: 2, Times: []float64{1, 2, 3}, Jobs: 2}, time.Millisecond},
+		{"seconds", SpeedResult{AverageElapsed: 0.002, Times: []float64{0.001, 0.002, 0.003}, Jobs: 2}, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr := NewPrintableResult().FromSpeedResult(tt.result, tt.timeUnit)
+			if pr.AverageElapsed != "2ms" {
+				t.Errorf("FromSpeedResult() average = %v, want 2ms", pr.AverageElapsed)
+			}
+			if want := "p50: 2ms, p95: 2.9ms, p99: 2.98ms"; pr.Latency != want {
+				t.Errorf("FromSpeedResult() latency = %v, want %v", pr.Latency, want)
+			}
+		})
+	}
+}
+
+func TestFromSpeedResultPersistentShell(t *testing.T) {
+	tests := []struct {
+		name            string
+		persistentShell bool
+		want            bool
+	}{
+		{"new shell", false, true},
+		// a persistent shell doesn't tell the user and system times
+		{"persistent shell", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sr := SpeedResult{AverageElapsed: 2000, AverageUser: 1500, AverageSystem: 500, Times: []float64{2000}, PersistentShell: tt.persistentShell}
+			summary := NewPrintableResult().FromSpeedResult(sr, time.Microsecond).String()
+			if got := strings.Contains(summary, "User:"); got != tt.want {
+				t.Errorf("FromSpeedResult().String() = %q, want the user and system times: %v", summary, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeoutSummary(t *testing.T) {
+	tests := []struct {
+		name     string
+		result   SpeedResult
+		timeUnit time.Duration
+		want     string
+	}{
+		{"no timeouts", SpeedResult{Times: []float64{10, 20}, TimedOut: []bool{false, false}}, time.Microsecond, ""},
+		{"microseconds", SpeedResult{Times: []float64{10, 500_000}, TimedOut: []bool{false, true}}, time.Microsecond, "1 of 2 (recorded as lasting the 500ms timeout, the statistics are lower bounds)"},
+		// as converted by ModifyTimeUnit for the exports
+		{"seconds", SpeedResult{Times: []float64{0.5, 0.00001}, TimedOut: []bool{true, false}}, time.Second, "1 of 2 (recorded as lasting the 500ms timeout, the statistics are lower bounds)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.result.timeoutSummary(tt.timeUnit); got != tt.want {
+				t.Errorf("timeoutSummary() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
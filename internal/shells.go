@@ -0,0 +1,119 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var ErrInvalidShellProfile = errors.New("invalid shell profile")
+
+// ShellProfilesPath is the config file custom shell profiles are read from, see [LoadShellProfiles].
+var ShellProfilesPath = filepath.Join(getBenchDir(), "shells.json")
+
+// ShellProfile tells how to execute a command string through a shell.
+// `CommandSwitch` are the arguments which precede the command string, e.g. `-c` or `/c`.
+// `NoRCFlags` are the arguments which keep the shell from reading its startup files, so that their
+// contents don't add to (or vary) the measured times.
+// `CalibrationCommand` is a command which does nothing, executed to measure the time the shell takes to spawn.
+// `POSIX` tells whether the shell understands POSIX shell syntax, which is needed to keep a persistent shell.
+type ShellProfile struct {
+	CommandSwitch      []string `json:"command_switch"`
+	NoRCFlags          []string `json:"no_rc_flags,omitempty"`
+	CalibrationCommand string   `json:"calibration_command"`
+	POSIX              bool     `json:"posix,omitempty"`
+}
+
+// the built-in profiles, keyed by the basename of the shell without any .exe extension
+var builtinShellProfiles = map[string]ShellProfile{
+	"sh":         {CommandSwitch: []string{"-c"}, CalibrationCommand: ":", POSIX: true},
+	"dash":       {CommandSwitch: []string{"-c"}, CalibrationCommand: ":", POSIX: true},
+	"ash":        {CommandSwitch: []string{"-c"}, CalibrationCommand: ":", POSIX: true},
+	"ksh":        {CommandSwitch: []string{"-c"}, CalibrationCommand: ":", POSIX: true},
+	"mksh":       {CommandSwitch: []string{"-c"}, CalibrationCommand: ":", POSIX: true},
+	"bash":       {CommandSwitch: []string{"-c"}, NoRCFlags: []string{"--norc", "--noprofile"}, CalibrationCommand: ":", POSIX: true},
+	"zsh":        {CommandSwitch: []string{"-c"}, NoRCFlags: []string{"-f"}, CalibrationCommand: ":", POSIX: true},
+	"csh":        {CommandSwitch: []string{"-c"}, NoRCFlags: []string{"-f"}, CalibrationCommand: ":"},
+	"tcsh":       {CommandSwitch: []string{"-c"}, NoRCFlags: []string{"-f"}, CalibrationCommand: ":"},
+	"fish":       {CommandSwitch: []string{"-c"}, NoRCFlags: []string{"--no-config"}, CalibrationCommand: "true"},
+	"nu":         {CommandSwitch: []string{"-c"}, NoRCFlags: []string{"--no-config-file"}, CalibrationCommand: "null"},
+	"elvish":     {CommandSwitch: []string{"-c"}, NoRCFlags: []string{"-norc"}, CalibrationCommand: "nop"},
+	"xonsh":      {CommandSwitch: []string{"-c"}, NoRCFlags: []string{"--no-rc"}, CalibrationCommand: "pass"},
+	"pwsh":       {CommandSwitch: []string{"-Command"}, NoRCFlags: []string{"-NoProfile", "-NonInteractive"}, CalibrationCommand: "$null"},
+	"powershell": {CommandSwitch: []string{"-Command"}, NoRCFlags: []string{"-NoProfile", "-NonInteractive"}, CalibrationCommand: "$null"},
+	// /d skips the AutoRun commands of the registry
+	"cmd": {CommandSwitch: []string{"/c"}, NoRCFlags: []string{"/d"}, CalibrationCommand: "rem"},
+}
+
+// the profile assumed for shells which have none
+var genericShellProfile = ShellProfile{CommandSwitch: []string{"-c"}, CalibrationCommand: ":"}
+
+// ShellName returns the name shell profiles are keyed by: the lowercase basename of the shell's path, without
+// any .exe extension. Both slashes and backslashes are treated as separators.
+func ShellName(shellPath string) string {
+	name := strings.ToLower(path.Base(strings.ReplaceAll(shellPath, `\`, "/")))
+	return strings.TrimSuffix(name, ".exe")
+}
+
+// LoadShellProfiles returns the built-in shell profiles along with the custom ones read from the given
+// config file, which override the built-in ones of the same name. The file holds a JSON object mapping
+// shell names to profiles, e.g. `{"mysh": {"command_switch": ["-e"], "calibration_command": "nop"}}`.
+// A missing file isn't an error.
+func LoadShellProfiles(file string) (map[string]ShellProfile, error) {
+	profiles := make(map[string]ShellProfile, len(builtinShellProfiles))
+	for name, profile := range builtinShellProfiles {
+		profiles[name] = profile
+	}
+	content, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return profiles, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var custom map[string]ShellProfile
+	if err := json.Unmarshal(content, &custom); err != nil {
+		return nil, fmt.Errorf("%w: cannot parse %s: %v", ErrInvalidShellProfile, file, err)
+	}
+	for name, profile := range custom {
+		if len(profile.CommandSwitch) == 0 || profile.CalibrationCommand == "" {
+			return nil, fmt.Errorf("%w: the profile of `%s` in %s needs both a command_switch and a calibration_command", ErrInvalidShellProfile, name, file)
+		}
+		profiles[ShellName(name)] = profile
+	}
+	return profiles, nil
+}
+
+// LookupShellProfile returns the profile of the shell at the given path, and whether there's one.
+// Shells without a profile get a generic one which passes the command with `-c`.
+func LookupShellProfile(profiles map[string]ShellProfile, shellPath string) (ShellProfile, bool) {
+	profile, ok := profiles[ShellName(shellPath)]
+	if !ok {
+		return genericShellProfile, false
+	}
+	return profile, true
+}
+
+// PersistentArgs returns the arguments which start the shell at the given path reading a script from
+// its stdin, for the shell to be kept executing commands. The shell's startup files are skipped unless loadRC is true.
+func (sp ShellProfile) PersistentArgs(shellPath string, loadRC bool) []string {
+	args := []string{shellPath}
+	if !loadRC {
+		args = append(args, sp.NoRCFlags...)
+	}
+	return append(args, "-s")
+}
+
+// Args returns the arguments which execute a command string through the shell at the given path, up to the
+// command string itself. The shell's startup files are skipped unless loadRC is true.
+func (sp ShellProfile) Args(shellPath string, loadRC bool) []string {
+	args := []string{shellPath}
+	if !loadRC {
+		args = append(args, sp.NoRCFlags...)
+	}
+	return append(args, sp.CommandSwitch...)
+}
@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestShellName(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"bash", "bash"},
+		{"/usr/bin/zsh", "zsh"},
+		{"/home/cmdtools/bin/fish", "fish"},
+		{`C:\Windows\System32\cmd.exe`, "cmd"},
+		{"C:/Program Files/PowerShell/7/PWSH.EXE", "pwsh"},
+	}
+	for _, tt := range tests {
+		if got := ShellName(tt.path); got != tt.want {
+			t.Errorf("ShellName(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestLoadShellProfiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+
+	profiles, err := LoadShellProfiles(filepath.Join(dir, "missing.json"))
+	if err != nil {
+		t.Fatalf("LoadShellProfiles() with a missing file error = %v", err)
+	}
+	if got, known := LookupShellProfile(profiles, "/opt/in-house/mysh"); known || !reflect.DeepEqual(got, genericShellProfile) {
+		t.Errorf("LookupShellProfile() of an unknown shell = %v, %v", got, known)
+	}
+
+	custom := write("shells.json", `{"MySh": {"command_switch": ["-e"], "no_rc_flags": ["--bare"], "calibration_command": "nop"}, "bash": {"command_switch": ["-c"], "calibration_command": "true"}}`)
+	profiles, err = LoadShellProfiles(custom)
+	if err != nil {
+		t.Fatalf("LoadShellProfiles() error = %v", err)
+	}
+	mysh, known := LookupShellProfile(profiles, "/opt/in-house/mysh")
+	if !known {
+		t.Fatal("LookupShellProfile() didn't find the custom profile")
+	}
+	if got, want := mysh.Args("/opt/in-house/mysh", false), []string{"/opt/in-house/mysh", "--bare", "-e"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Args() = %v, want %v", got, want)
+	}
+	if got, want := mysh.Args("mysh", true), []string{"mysh", "-e"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Args() with the startup files = %v, want %v", got, want)
+	}
+	if bash, _ := LookupShellProfile(profiles, "/bin/bash"); bash.CalibrationCommand != "true" || len(bash.NoRCFlags) != 0 {
+		t.Errorf("the custom profile didn't override the built-in one: %v", bash)
+	}
+	if cmd, _ := LookupShellProfile(profiles, "cmd.exe"); !reflect.DeepEqual(cmd.Args("cmd.exe", false), []string{"cmd.exe", "/d", "/c"}) {
+		t.Errorf("the built-in profiles were lost: %v", cmd)
+	}
+
+	for _, content := range []string{`{"mysh": {"calibration_command": "nop"}}`, `{"mysh": {"command_switch": ["-e"]}}`, `[]`} {
+		if _, err := LoadShellProfiles(write("invalid.json", content)); !errors.Is(err, ErrInvalidShellProfile) {
+			t.Errorf("LoadShellProfiles(%s) error = %v, want %v", content, err, ErrInvalidShellProfile)
+		}
+	}
+}
@@ -1,8 +1,12 @@
 package internal
 
 import (
+	"errors"
 	"math"
+	"slices"
 	"sort"
+	"strings"
+	"time"
 
 	"github.com/mitchellh/colorstring"
 )
@@ -35,6 +39,31 @@ func CalculateStandardDeviation(data []float64, avg float64) float64 {
 	return roundFloat(deviationSum, 2)
 }
 
+// CalculatePercentile returns the p-th percentile (0 <= p <= 100) of the data, interpolating linearly
+// between the closest ranks. The data is left untouched.
+func CalculatePercentile(data []float64, p float64) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(data)
+	slices.Sort(sorted)
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+// DetermineRuns returns the number of runs which makes a benchmark both perform at least minRuns runs
+// and last at least minDuration, given the duration of a single run, without exceeding maxRuns.
+func DetermineRuns(singleRuntime time.Duration, minRuns, maxRuns int, minDuration time.Duration) int {
+	singleRuntime = max(singleRuntime, time.Nanosecond)
+	if singleRuntime*time.Duration(minRuns) > minDuration {
+		return minRuns
+	}
+	runs := int(float64(minDuration) / float64(singleRuntime))
+	return min(runs, maxRuns)
+}
+
 // returns a slice of absolute z-scores of each data point
 func calculateModifiedZScore(data []float64) []float64 {
 	median := calculateMedian(data)
@@ -81,25 +110,76 @@ func TestOutliers(data []float64) bool {
 	return (nOutliers / totalDataPoints * 100) > OUTLIER_THRESHOLD
 }
 
-// Prints the relative summary and also sets the RelativeMean and RelativeStddev of each [SpeedResult].
-func RelativeSummary(results []*SpeedResult) {
+// Metric is a quantity by which the commands are compared in the relative summary.
+type Metric int
+
+const (
+	TimeMetric Metric = iota
+	MemoryMetric
+)
+
+var ErrInvalidMetric = errors.New("invalid metric")
+
+func ParseMetric(metricString string) (Metric, error) {
+	switch strings.TrimSpace(strings.ToLower(metricString)) {
+	case "time":
+		return TimeMetric, nil
+	case "memory":
+		return MemoryMetric, nil
+	default:
+		return 0, ErrInvalidMetric
+	}
+}
+
+// returns the ratio of the means a/b along with its propagated standard deviation
+func relativeRatio(mean, stddev, baseMean, baseStddev float64) (float64, float64) {
+	ratio := mean / baseMean
+	ratioStddev := ratio * math.Sqrt(
+		math.Pow(stddev/mean, 2)+
+			math.Pow(baseStddev/baseMean, 2),
+	)
+	return ratio, ratioStddev
+}
+
+// Prints the relative summary as per the given metric. With [TimeMetric], it sets the RelativeMean and
+// RelativeStddev of each [SpeedResult], with [MemoryMetric] their RelativeMemory and RelativeMemoryStddev.
+func RelativeSummary(results []*SpeedResult, metric Metric) {
 	if len(results) <= 1 {
 		return
 	}
+	if metric == MemoryMetric {
+		relativeMemorySummary(results)
+		return
+	}
 	sort.Sort(ByAverage(results))
 	fastest := results[0]
 	fastest.RelativeMean = 1.00
 	fastest.RelativeStddev = 0.00
 	colorstring.Println("[bold][white]Summary")
-	colorstring.Printf("  [cyan]%s[reset] ran \n", fastest.Command)
+	colorstring.Printf("  [cyan]%s[reset] ran \n", fastest.label())
 	for _, r := range results[1:] {
-		ratio := r.AverageElapsed / fastest.AverageElapsed
-		ratioStddev := ratio * math.Sqrt(
-			math.Pow(r.StandardDeviation/r.AverageElapsed, 2)+
-				math.Pow(fastest.StandardDeviation/fastest.AverageElapsed, 2),
-		)
+		ratio, ratioStddev := relativeRatio(r.AverageElapsed, r.StandardDeviation, fastest.AverageElapsed, fastest.StandardDeviation)
 		r.RelativeMean = ratio
 		r.RelativeStddev = ratioStddev
-		colorstring.Printf("    [green]%.2f[reset] ± [light_green]%.2f[reset] times faster than [magenta]%s \n", ratio, ratioStddev, r.Command)
+		colorstring.Printf("    [green]%.2f[reset] ± [light_green]%.2f[reset] times faster than [magenta]%s \n", ratio, ratioStddev, r.label())
+	}
+}
+
+func relativeMemorySummary(results []*SpeedResult) {
+	sort.Sort(ByMemory(results))
+	leanest := results[0]
+	if leanest.AverageMemory == 0 {
+		Log("yellow", "Memory usage isn't available on this platform, the memory summary can't be shown.")
+		return
+	}
+	leanest.RelativeMemory = 1.00
+	leanest.RelativeMemoryStddev = 0.00
+	colorstring.Println("[bold][white]Summary")
+	colorstring.Printf("  [cyan]%s[reset] used \n", leanest.label())
+	for _, r := range results[1:] {
+		ratio, ratioStddev := relativeRatio(r.AverageMemory, r.MemoryStddev, leanest.AverageMemory, leanest.MemoryStddev)
+		r.RelativeMemory = ratio
+		r.RelativeMemoryStddev = ratioStddev
+		colorstring.Printf("    [green]%.2f[reset] ± [light_green]%.2f[reset] times less memory than [magenta]%s \n", ratio, ratioStddev, r.label())
 	}
 }
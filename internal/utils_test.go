@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"math"
 	"os"
 	"reflect"
 	"strconv"
@@ -145,3 +146,133 @@ func TestMapFunc(t *testing.T) {
 		})
 	}
 }
+
+func TestRepeatedFlagValues(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"absent", []string{"sleep 1", "-r", "10"}, nil},
+		{"long and short", []string{"a", "b", "--command-name", "first", "-r", "5", "-n", "second"}, []string{"first", "second"}},
+		{"equals", []string{"--command-name=x=y", "-n", "z"}, []string{"x=y", "z"}},
+		{"missing value", []string{"-n", "--verbose", "-n"}, nil},
+		{"other flags", []string{"--name", "a", "-N", "b"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RepeatedFlagValues(tt.args, "command-name", "n"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RepeatedFlagValues() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLabel(t *testing.T) {
+	tests := []struct {
+		name   string
+		result SpeedResult
+		want   string
+	}{
+		{"command", SpeedResult{Command: "sleep 1"}, "sleep 1"},
+		{"named", SpeedResult{Command: "sleep 1", Name: "s1"}, "s1"},
+		{"parameters", SpeedResult{Command: "sleep 1", Parameters: map[string]string{"n": "1"}}, "sleep 1 (n=1)"},
+		{"named with parameters", SpeedResult{Command: "sleep 1", Name: "s1", Parameters: map[string]string{"n": "1", "m": "x"}}, "s1 (m=x, n=1)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.result.label(); got != tt.want {
+				t.Errorf("label() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		size    string
+		want    int64
+		wantErr bool
+	}{
+		{"512", 512, false},
+		{"512B", 512, false},
+		{"64k", 64 << 10, false},
+		{"10MiB", 10 << 20, false},
+		{"1 GB", 1 << 30, false},
+		{"", 0, true},
+		{"-1", 0, true},
+		{"1.5M", 0, true},
+		{"ten", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.size, func(t *testing.T) {
+			got, err := ParseByteSize(tt.size)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseByteSize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseByteSize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		bytes float64
+		want  string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1536, "1.50 KiB"},
+		{10 << 20, "10.00 MiB"},
+		{3 << 30, "3.00 GiB"},
+		{5 << 40, "5120.00 GiB"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := FormatBytes(tt.bytes); got != tt.want {
+				t.Errorf("FormatBytes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalculatePercentile(t *testing.T) {
+	data := []float64{4, 1, 3, 2, 5}
+	tests := []struct {
+		p    float64
+		want float64
+	}{
+		{0, 1},
+		{50, 3},
+		{95, 4.8},
+		{100, 5},
+	}
+	for _, tt := range tests {
+		if got := CalculatePercentile(data, tt.p); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("CalculatePercentile(%v) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+	if data[0] != 4 {
+		t.Errorf("CalculatePercentile() modified the data")
+	}
+}
+
+func TestDetermineRuns(t *testing.T) {
+	tests := []struct {
+		single time.Duration
+		want   int
+	}{
+		{time.Second, 10},
+		{100 * time.Millisecond, 30},
+		{time.Millisecond, 3000},
+		{time.Microsecond, 5000},
+		{0, 5000},
+	}
+	for _, tt := range tests {
+		if got := DetermineRuns(tt.single, 10, 5000, 3*time.Second); got != tt.want {
+			t.Errorf("DetermineRuns(%v) = %v, want %v", tt.single, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+
+	"github.com/shravanasati/atomic/internal"
+)
+
+// errInterrupted is the error of the commands which were stopped because atomic was interrupted.
+var errInterrupted = errors.New("interrupted")
+
+// interruptHandler handles the interrupts (Ctrl+C) atomic receives while benchmarking. The first interrupt stops
+// the benchmark: the runs in flight are stopped and no run is started anymore, but the results collected so far
+// are summarized, exported and plotted as usual. The second interrupt makes atomic exit immediately, killing the
+// process groups of the commands which are still running.
+type interruptHandler struct {
+	// closed on the first interrupt
+	stopped chan struct{}
+	mu      sync.Mutex
+	// the processes which have been started and haven't exited yet, each leading its process group
+	running map[*os.Process]struct{}
+}
+
+var interrupts = &interruptHandler{stopped: make(chan struct{}), running: map[*os.Process]struct{}{}}
+
+// listen starts handling the interrupts.
+func (ih *interruptHandler) listen() {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt)
+	go func() {
+		<-signals
+		close(ih.stopped)
+		internal.Log("yellow", "\nInterrupted, stopping the benchmark to summarize the runs so far. Interrupt again to exit immediately.")
+		<-signals
+		// the lock is never released, so that no process is started anymore
+		ih.mu.Lock()
+		for process := range ih.running {
+			killProcessGroup(process)
+		}
+		os.Exit(130)
+	}()
+}
+
+// interrupted tells whether atomic was interrupted.
+func (ih *interruptHandler) interrupted() bool {
+	select {
+	case <-ih.stopped:
+		return true
+	default:
+		return false
+	}
+}
+
+// start starts the command, restricted to the given cpus, and keeps track of it until [interruptHandler.exited]
+// is called, so that it's killed if atomic exits on an interrupt.
+func (ih *interruptHandler) start(cmd *exec.Cmd, cpus []int) error {
+	ih.mu.Lock()
+	defer ih.mu.Unlock()
+	if err := startWithAffinity(cmd, cpus); err != nil {
+		return err
+	}
+	ih.running[cmd.Process] = struct{}{}
+	return nil
+}
+
+// track keeps track of a process which leads a process group, until [interruptHandler.exited] is called.
+func (ih *interruptHandler) track(process *os.Process) {
+	ih.mu.Lock()
+	defer ih.mu.Unlock()
+	ih.running[process] = struct{}{}
+}
+
+// exited stops keeping track of a process started with [interruptHandler.start] or tracked, once it has exited.
+func (ih *interruptHandler) exited(process *os.Process) {
+	ih.mu.Lock()
+	defer ih.mu.Unlock()
+	delete(ih.running, process)
+}
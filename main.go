@@ -2,17 +2,20 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"math"
+	"math/rand"
 	"os"
 	"os/exec"
-	"strconv"
-
-	// "path/filepath"
+	"path/filepath"
 	"runtime"
 	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/shlex"
@@ -75,29 +78,47 @@ func getDefaultShell() (string, error) {
 	}
 }
 
-// todo write tests
-
 // builds the given command as per the given params.
-// if useShell is true, adds a shell in front of the command.
-// the shell is determined by the shellPath.
+// if shellArgs isn't empty, the command is executed through a shell, shellArgs being the arguments
+// which precede the command string (see [internal.ShellProfile.Args]), and the command is passed
+// verbatim as a single argument. otherwise it's split into arguments the way a posix shell would.
 // returns the built command and an error.
-func buildCommand(command string, useShell bool, shellPath string) ([]string, error) {
-	var builtCommand []string
-	var err error
-	if useShell {
-		// the flag that enables execution of command from a string
-		// e.g. -Command or -c on pwsh, /c on cmd.exe, -c on any other shell
-		var commandSwitch string
-		if strings.Contains(shellPath, "cmd.exe") || strings.Contains(shellPath, "cmd") {
-			commandSwitch = "/c"
-		} else {
-			commandSwitch = "-c"
+func buildCommand(command string, shellArgs []string) ([]string, error) {
+	if len(shellArgs) == 0 {
+		return shlex.Split(command)
+	}
+	return append(slices.Clone(shellArgs), command), nil
+}
+
+// cmdExeCommandLine returns the command line which hands the command string following the /c switch of cmd.exe
+// over verbatim, since cmd.exe doesn't understand the escaping (\") of the command line Go builds from the
+// arguments. With /s, cmd.exe strips the quotes around the command string and leaves the rest untouched.
+// Returns false if the arguments don't execute a command string with cmd.exe.
+func cmdExeCommandLine(args []string) (string, bool) {
+	if len(args) < 3 || internal.ShellName(args[0]) != "cmd" || !strings.EqualFold(args[len(args)-2], "/c") {
+		return "", false
+	}
+	parts := make([]string, 0, len(args)+1)
+	for _, arg := range args[:len(args)-2] {
+		if arg == "" || strings.ContainsAny(arg, " \t") {
+			arg = `"` + arg + `"`
 		}
-		builtCommand, err = shlex.Split(fmt.Sprintf("\"%s\" %s \"%s\"", shellPath, commandSwitch, command))
-	} else {
-		builtCommand, err = shlex.Split(command)
+		parts = append(parts, arg)
+	}
+	parts = append(parts, "/s", args[len(args)-2], `"`+args[len(args)-1]+`"`)
+	return strings.Join(parts, " "), true
+}
+
+// parses a command given as a JSON array of its arguments with the --argv flag, which are used as is.
+func parseArgv(command string) ([]string, error) {
+	var argv []string
+	if err := json.Unmarshal([]byte(command), &argv); err != nil {
+		return nil, fmt.Errorf("expected a JSON array of strings like [\"grep\", \"-r\", \"a b\"]: %w", err)
+	}
+	if len(argv) == 0 || argv[0] == "" {
+		return nil, errors.New("the JSON array must start with the program to execute")
 	}
-	return builtCommand, err
+	return argv, nil
 }
 
 type failedProcessError struct {
@@ -116,32 +137,134 @@ func (fpe *failedProcessError) handle() {
 		internal.Log("yellow", "This happened due to the -t/--timeout flag. Consider increasing the timeout duration for successfull execution of the command.")
 		return
 	}
+	switch fpe.where {
+	case setupStage:
+		internal.Log("yellow", "The --setup command must succeed for the benchmark to be performed. Use the -V/--verbose flag to show its output.")
+		return
+	case concludeStage:
+		internal.Log("yellow", "The results of a command are discarded if its --conclude command fails. Use the -V/--verbose flag to show its output.")
+		return
+	}
 	internal.Log("yellow", "You should consider using -I/--ignore-error flag to ignore failures in the command execution. Alternatively, you can also try the -V/--verbose flag to show the output of the command. If the command is actually a shell function, use -s/--shell flag to execute it via a shell.")
 }
 
+// generateInput writes the given number of bytes to a temporary file and returns its path.
+// fill must be either `random` or `zero`. Random bytes are generated with a fixed seed, so that
+// the input is the same across benchmarks too.
+func generateInput(size int64, fill string) (string, error) {
+	var source io.Reader
+	switch fill {
+	case "random":
+		source = rand.New(rand.NewSource(0))
+	case "zero":
+		source = zeroReader{}
+	default:
+		return "", fmt.Errorf("invalid input fill `%s`, must be either random or zero", fill)
+	}
+
+	f, err := os.CreateTemp("", "atomic-input-*")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err = io.CopyN(f, source, size); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// values of `where` in [failedProcessError] for the commands executed once per benchmarked command
+const (
+	setupStage    = "setup"
+	concludeStage = "conclude"
+)
+
+// runStage executes the setup or conclude command of a benchmark once, its output is only shown
+// in verbose mode. The command is stopped when `stop` is closed. Returns true if the command failed,
+// after reporting the failure, or if it was stopped.
+func runStage(command []string, stage string, verbose bool, env []string, dir string, stop <-chan struct{}) bool {
+	result := RunCommand(&RunOptions{
+		command:     command,
+		output:      verboseOutput(verbose),
+		ignoreError: false,
+		timeout:     LargestDuration,
+		env:         env,
+		dir:         dir,
+		stop:        stop,
+	})
+	var processErr *failedProcessError
+	if errors.As(result.err, &processErr) {
+		// the interrupt has been reported already
+		if !errors.Is(processErr.err, errInterrupted) {
+			processErr.where = stage
+			processErr.handle()
+		}
+		return true
+	}
+	return false
+}
+
 // RunOptions represents options accepted by [RunCommand].
-// `command` is a slice of string representing a (shlex-) split command to execute.
-// `verbose` is a bool value indicating whether [os/exec.Cmd.Stdout] should be redirected to [os.Stdout].
-// `ignoreError` is a bool value indicating whether any errors in the starting or waiting procedure
-// should be ignored.
-// `timeout` is used in the [context.WithTimeout] function, and the resulting context is used in
-// [os/exec.CommandContext].
 type RunOptions struct {
-	command     []string
-	verbose     bool
+	// the (shlex-) split command to execute
+	command []string
+	// where [os/exec.Cmd.Stdout] and [os/exec.Cmd.Stderr] are redirected
+	output outputMode
+	// whether errors in the starting or waiting procedure are ignored
 	ignoreError bool
-	timeout     time.Duration
+	// the non-zero exit codes which aren't errors
+	acceptedExitCodes []int
+	// the time the command may take, after which its whole process group is stopped
+	timeout time.Duration
+	// returns a run which timed out as a censored run lasting the timeout, instead of failing it
+	recordTimeout bool
+	// the file opened anew for every run and fed to stdin, the null device if empty
+	input string
+	// the names of the perf counters to measure the command with
+	perfCounters []string
+	// starts the command through a helper on Linux, so that its peak memory usage doesn't include the memory
+	// atomic uses, see [measurement]
+	exactMemory bool
+	// the cpus the command is restricted to, any cpu if empty
+	cpus []int
+	// `KEY=VALUE` pairs, atomic's own environment if nil
+	env []string
+	// the working directory, atomic's own if empty
+	dir string
+	// stops the command when closed, see [interruptHandler], nil if the command can't be stopped
+	stop <-chan struct{}
 }
 
 // RunResult represents a result returned by [RunCommand].
 // `elapsed` is total elapsed duration spent waiting for the process.
-// `user` and `system` are both retrieved from [os/exec.Cmd.ProcessState].
+// `user` and `system` are both retrieved from the [processState] of the process.
+// `outputBytes` is the number of bytes written by the process, only counted for [pipeOutput].
+// `exitCode` is the exit code of the process, -1 if it was terminated by `signal`.
+// `timedOut` tells that the run was stopped by the timeout and recorded anyway, `elapsed` being the timeout then.
+// `maxRSS` is the peak resident set size of the process in bytes, zero where it isn't available.
+// `rusage` holds the context switches, page faults and block I/O counts of the process.
+// `perfCounters` holds the values of the perf counters the process was measured with.
 // `err` is of type [failedProcessError].
 type RunResult struct {
-	elapsed time.Duration
-	user    time.Duration
-	system  time.Duration
-	err     error
+	elapsed      time.Duration
+	user         time.Duration
+	system       time.Duration
+	maxRSS       int64
+	rusage       internal.ResourceUsage
+	perfCounters map[string]float64
+	outputBytes  int64
+	exitCode     int
+	signal       string
+	timedOut     bool
+	err          error
 }
 
 // Returns an empty [RunResult].
@@ -158,38 +281,111 @@ func emptyRunResult() *RunResult {
 func RunCommand(runOpts *RunOptions) *RunResult {
 	var cmd *exec.Cmd
 	runResult := emptyRunResult()
-	ctx, cancel := context.WithTimeout(context.Background(), runOpts.timeout)
-	defer cancel()
-	cmd = exec.CommandContext(ctx, runOpts.command[0], runOpts.command[1:]...)
+	cmd = exec.Command(runOpts.command[0], runOpts.command[1:]...)
+	setCommandLine(cmd)
+	setProcessGroup(cmd)
+	cmd.Env = runOpts.env
+	cmd.Dir = runOpts.dir
+
+	finishOutput, e := setOutput(cmd, runOpts.output)
+	if e != nil {
+		runResult.err = &failedProcessError{command: runOpts.command, err: e, where: "opening the output"}
+		return runResult
+	}
+
+	// opening the input anew for every run makes sure that every run reads the same data from the start
+	if runOpts.input != "" {
+		input, e := os.Open(runOpts.input)
+		if e != nil {
+			runResult.err = &failedProcessError{command: runOpts.command, err: e, where: "opening the input"}
+			return runResult
+		}
+		defer input.Close()
+		cmd.Stdin = input
+	}
 
-	if runOpts.verbose {
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+	// the perf counters are opened on the command through the helper too
+	measure, e := prepareMeasurement(cmd, runOpts.exactMemory || len(runOpts.perfCounters) != 0)
+	if e != nil {
+		finishOutput()
+		runResult.err = &failedProcessError{command: runOpts.command, err: e, where: "preparing the measurement"}
+		return runResult
 	}
+	defer measure.close()
 
-	var e error
 	init := time.Now()
-	if e = cmd.Start(); e != nil {
+	if e = interrupts.start(cmd, runOpts.cpus); e != nil {
+		finishOutput()
 		runResult.err = &failedProcessError{command: runOpts.command, err: e, where: "starting"}
 		return runResult
 	}
-	e = cmd.Wait()
-	duration := time.Since(init)
-
-	if e != nil {
-		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
-			runResult.err = &failedProcessError{command: runOpts.command, err: context.DeadlineExceeded, where: "execution"}
-			return runResult
-		}
-		if !runOpts.ignoreError {
-			runResult.err = &failedProcessError{command: runOpts.command, err: e, where: "execution"}
+	defer interrupts.exited(cmd.Process)
+	measure.started()
+	if e = measure.ready(); e != nil {
+		cmd.Wait()
+		finishOutput()
+		runResult.err = &failedProcessError{command: runOpts.command, err: e, where: "starting"}
+		return runResult
+	}
+	if measure.group != cmd.Process {
+		interrupts.track(measure.group)
+		defer interrupts.exited(measure.group)
+	}
+	var perf *perfSession
+	if len(runOpts.perfCounters) != 0 {
+		perf, e = openPerfSession(measure.pid, runOpts.perfCounters)
+		if e != nil {
+			// closing the pipes makes the command exit without executing
+			measure.close()
+			measure.wait()
+			finishOutput()
+			runResult.err = &failedProcessError{command: runOpts.command, err: e, where: "attaching the perf counters"}
 			return runResult
 		}
+		defer perf.close()
+	}
+	if measure.held() {
+		// the command only executes now, the helper has been holding it so far
+		init = time.Now()
+	}
+	if e = measure.start(); e != nil {
+		measure.wait()
+		finishOutput()
+		runResult.err = &failedProcessError{command: runOpts.command, err: e, where: "starting"}
+		return runResult
+	}
+	stopped, e := waitWithTimeout(measure.group, measure.wait, runOpts.timeout, runOpts.stop)
+	duration := measure.exited.Sub(init)
+	runResult.outputBytes = finishOutput()
+	state := measure.state()
+
+	switch {
+	case stopped != nil && (errors.Is(stopped, errInterrupted) || !runOpts.recordTimeout):
+		runResult.err = &failedProcessError{command: runOpts.command, err: stopped, where: "execution"}
+		return runResult
+	case stopped != nil:
+		// all that is known is that the run would have lasted at least the timeout
+		runResult.timedOut = true
+		duration = runOpts.timeout
+	case e != nil && !runOpts.ignoreError && !slices.Contains(runOpts.acceptedExitCodes, state.ExitCode()):
+		runResult.err = &failedProcessError{command: runOpts.command, err: e, where: "execution"}
+		return runResult
 	}
 
 	runResult.elapsed = duration
-	runResult.user = cmd.ProcessState.UserTime()
-	runResult.system = cmd.ProcessState.SystemTime()
+	runResult.user = state.UserTime()
+	runResult.system = state.SystemTime()
+	runResult.exitCode = state.ExitCode()
+	runResult.signal = terminationSignal(state)
+	runResult.maxRSS = peakMemory(state)
+	runResult.rusage = resourceUsage(state)
+	if perf != nil {
+		runResult.perfCounters, e = perf.read()
+		if e != nil {
+			runResult.err = &failedProcessError{command: runOpts.command, err: e, where: "reading the perf counters"}
+			return runResult
+		}
+	}
 
 	return runResult
 }
@@ -203,12 +399,7 @@ var MinDuration = (3 * time.Second).Microseconds()
 // 1. Minimum number of runs to be performed: 10
 // 2. Minimum duration the benchmark should last: 3s
 func determineRuns(singleRuntime time.Duration) int {
-	if (singleRuntime.Microseconds() * int64(MinRuns)) > MinDuration {
-		return MinRuns
-	} else {
-		runs := int(float64(MinDuration) / float64(singleRuntime.Microseconds()))
-		return min(runs, MaxRuns)
-	}
+	return internal.DetermineRuns(singleRuntime, MinRuns, MaxRuns, time.Duration(MinDuration)*time.Microsecond)
 }
 
 type benchmarkMode int
@@ -219,260 +410,836 @@ const (
 	mainMode   benchmarkMode = 2
 )
 
-// BenchmarkOptions represents benchmarking options accepted by [Benchmark].
-//
-// `command` is a slice of string representing a (shlex-) split command to execute.
-// `verbose` is a bool value indicating whether [os/exec.Cmd.Stdout] should be redirected to [os.Stdout].
-// `ignoreError` is a bool value indicating whether any errors in the starting or waiting procedure
-// should be ignored.
-// `timeout` is used in the [context.WithTimeout] function, and the resulting context is used in
-// [os/exec.CommandContext].
-// All these above parameters are passed to [RunCommand] in form of [RunOptions].
-//
-// `executePrepareCmd` is a bool value indicating whether to execute prepare commands.
-// `prepareCmd` is similar to `command` except it's used to execute prepare command if `executePrepareCmd` is set to true.
-//
-// `executeCleanupCmd` is a bool value indicating whether to execute cleanup commands.
-// `cleanupCmd` is similar to `command` except it's used to execute cleanup command if `executeCleanupCmd` is set to true.
-//
-// `shellCalibration` is a *[RunResult] and is substracted from every run duration, `elapsed`, `user` and `system`.
-// `mode` is a [benchmarkMode] and must be one of `shellMode`, `warmupMode` and `mainMode`. These different modes are used for progress bar descriptions and such.
+// BenchmarkOptions represents benchmarking options accepted by [Benchmark], shared by all the benchmarked commands.
+// Most of them are passed on to [RunCommand] as [RunOptions], the prepare and cleanup commands get their
+// output as per `verbose` and no input.
 type BenchmarkOptions struct {
-	command           []string
-	runs              int
+	// the number of runs of every command, determined from a single run of the command if negative
+	runs int
+	// logs every run instead of showing a progress bar, and shows the output of the prepare and cleanup commands
 	verbose           bool
+	output            outputMode
 	ignoreError       bool
-	executePrepareCmd bool
-	prepareCmd        []string
-	executeCleanupCmd bool
-	cleanupCmd        []string
-	shellCalibration  *RunResult
-	mode              benchmarkMode
-	timeout           time.Duration
-}
-
-// Benchmark runs the given command as per the given opts and returns a slice of durations in
-// microseconds as well as the number of runs performed and whether the Benchmark was NOT successful.
-func Benchmark(opts BenchmarkOptions) ([]*RunResult, bool) {
-	// actual runs, each entry stored in microseconds
-	var runsData []*RunResult
-	wordMap := map[benchmarkMode]string{
-		shellMode:  "shell",
-		warmupMode: "warmup",
-		mainMode:   "iteration",
+	acceptedExitCodes []int
+	// the number of times a failed run is retried, and the runs of a command which may fail after all
+	retries     int
+	maxFailures internal.FailureBudget
+	// subtracted from every run duration, `elapsed`, `user` and `system`
+	shellCalibration *RunResult
+	// used for progress bar descriptions and such
+	mode benchmarkMode
+	// the time a single run may take, and what happens to the runs which exceed it
+	timeout      time.Duration
+	onTimeout    timeoutAction
+	input        string
+	perfCounters []string
+	// the order of the runs of the commands, `rng` shuffles them for `shuffledOrder`
+	order runOrder
+	rng   *rand.Rand
+	// the time budget of the benchmark, the commands are run in rounds until it's spent if positive
+	duration time.Duration
+	// the relative half-width the 95% confidence interval of the `precisionStat` of the run time is
+	// narrowed down to, the commands are run until it's reached if positive
+	precision     float64
+	precisionStat internal.Statistic
+	// the number of slots executing runs concurrently, the commands get the slot number in `ATOMIC_SLOT`
+	jobs int
+	// the arguments starting the shell the commands are executed in, nil if a process is spawned for every
+	// run, and the script the shell evaluates first
+	persistentShell []string
+	shellInit       string
+}
+
+// adaptive tells whether the number of runs is decided while benchmarking, rather than upfront.
+func (bo *BenchmarkOptions) adaptive() bool {
+	return bo.duration > 0 || bo.precision > 0
+}
+
+// benchmarkCommand is a single command to benchmark, built from a [benchmarkTarget], along with the
+// state of its benchmark. Nil prepare, cleanup, setup and conclude commands are not executed.
+// `cpus` are the cpus every run of the command is restricted to.
+type benchmarkCommand struct {
+	// position of the target among all the targets, used for the headings
+	index       int
+	target      benchmarkTarget
+	command     []string
+	prepareCmd  []string
+	cleanupCmd  []string
+	setupCmd    []string
+	concludeCmd []string
+	cpus        []int
+	// the environment of all the commands, atomic's own environment if nil
+	env []string
+	// the working directory of all the commands, atomic's own if empty
+	dir string
+	// the absolute path of the fixture copied to a temporary directory for every iteration, which becomes the
+	// working directory of the prepare and cleanup commands and of the run, empty if the runs aren't isolated
+	fixture string
+	// guards the state below, which concurrent slots update
+	mu sync.Mutex
+	// results of the runs of the last [Benchmark], in microseconds
+	runsData []*RunResult
+	// number of runs of the last [Benchmark] which have been started
+	started int
+	// number of runs the last [Benchmark] performs, which the failure budget is relative to, 0 if unknown upfront
+	planned int
+	// number of failed runs of the last [Benchmark] which were retried
+	retries int
+	// why the runs of the last [Benchmark] which were discarded failed
+	discarded []string
+	// set when the last [Benchmark] was cut short by an interrupt
+	interrupted bool
+	// set once the command (or its prepare, cleanup, setup or conclude command) has failed,
+	// the command isn't run anymore then
+	failed bool
+	// when the first run of the last [Benchmark] started and the last one ended
+	firstStart time.Time
+	lastEnd    time.Time
+	// the persistent shells the runs are executed in, by slot, kept from one [Benchmark] to the next
+	shells map[int]*persistentShell
+}
+
+// runInShell executes a run of the command in the persistent shell of the slot, starting the shell first if
+// needed. A shell which failed is closed, and replaced by a new one for the next run.
+// The run is executed in the given directory, if any.
+func (bc *benchmarkCommand) runInShell(slot int, opts *BenchmarkOptions, env []string, dir string) *RunResult {
+	failed := func(err error, where string) *RunResult {
+		runResult := emptyRunResult()
+		runResult.err = &failedProcessError{command: []string{bc.target.command}, err: err, where: where}
+		return runResult
+	}
+	bc.mu.Lock()
+	shell := bc.shells[slot]
+	bc.mu.Unlock()
+	if shell == nil {
+		var err error
+		shell, err = startPersistentShell(opts.persistentShell, opts.shellInit, opts.output, bc.cpus, env, bc.dir, opts.timeout)
+		if err != nil {
+			return failed(err, "starting the persistent shell")
+		}
+		bc.mu.Lock()
+		if bc.shells == nil {
+			bc.shells = map[int]*persistentShell{}
+		}
+		bc.shells[slot] = shell
+		bc.mu.Unlock()
+	}
+
+	runResult, err := shell.run(bc.target.command, opts.input, dir, opts.timeout, interrupts.stopped)
+	if err != nil {
+		timedOut := errors.Is(err, context.DeadlineExceeded)
+		if timedOut || errors.Is(err, errInterrupted) {
+			shell.kill()
+		} else {
+			shell.close()
+		}
+		bc.mu.Lock()
+		delete(bc.shells, slot)
+		bc.mu.Unlock()
+		if timedOut && opts.onTimeout == recordOnTimeout {
+			// the state of the shell is lost, the next run starts a new one
+			runResult = emptyRunResult()
+			runResult.elapsed = opts.timeout
+			runResult.timedOut = true
+			return runResult
+		}
+		return failed(err, "execution")
+	}
+	if runResult.exitCode != 0 && !opts.ignoreError && !slices.Contains(opts.acceptedExitCodes, runResult.exitCode) {
+		runResult.err = &failedProcessError{command: []string{bc.target.command}, err: fmt.Errorf("exit status %d", runResult.exitCode), where: "execution"}
+	}
+	return runResult
+}
+
+// closeShells makes the persistent shells of the command exit.
+func (bc *benchmarkCommand) closeShells() {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	for _, shell := range bc.shells {
+		shell.close()
+	}
+	bc.shells = nil
+}
+
+// throughput returns the number of runs per second the command achieved in the last [Benchmark].
+func (bc *benchmarkCommand) throughput() float64 {
+	window := bc.lastEnd.Sub(bc.firstStart)
+	if window <= 0 {
+		return 0
+	}
+	return float64(len(bc.runsData)) / window.Seconds()
+}
+
+// elapsedTimes returns the elapsed time of every run of the command so far, in microseconds.
+// Must be called with the lock of the command held.
+func (bc *benchmarkCommand) elapsedTimes() []float64 {
+	return internal.MapFunc[[]*RunResult, []float64](func(r *RunResult) float64 { return float64(r.elapsed.Microseconds()) }, bc.runsData)
+}
+
+// precision returns the relative half-width of the 95% confidence interval of the given statistic of the
+// command's run time, over the runs completed so far.
+func (bc *benchmarkCommand) precision(stat internal.Statistic) float64 {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	return internal.RelativePrecision(bc.elapsedTimes(), stat)
+}
+
+func (bc *benchmarkCommand) isFailed() bool {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	return bc.failed
+}
+
+// discard drops a run which failed after all its retries if the failure budget allows it, otherwise the
+// command fails. Returns false if the command failed.
+func (bc *benchmarkCommand) discard(runErr *failedProcessError, opts *BenchmarkOptions, progress *benchmarkProgress) bool {
+	bc.mu.Lock()
+	// adaptive benchmarks don't know their number of runs upfront, the budget is relative to the runs so far
+	runs := bc.planned
+	if runs == 0 {
+		runs = max(bc.started, MinRuns)
+	}
+	if !bc.failed && opts.maxFailures.Allows(len(bc.discarded)+1, runs) {
+		bc.discarded = append(bc.discarded, failureReason(runErr))
+		progress.finished(bc)
+		bc.mu.Unlock()
+		return true
+	}
+	bc.mu.Unlock()
+	if !opts.maxFailures.IsZero() {
+		runErr.err = fmt.Errorf("%w, and the runs which failed exceed the --max-failures budget of %s", runErr.err, opts.maxFailures)
+	}
+	bc.fail(runErr, progress)
+	return false
+}
+
+// failureReason describes why a run failed, e.g. `exit status 1` or `timeout`.
+func failureReason(err *failedProcessError) string {
+	if errors.Is(err.err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+	return err.err.Error()
+}
+
+// heading returns the name of the command if one was given, otherwise the command itself.
+func (bc *benchmarkCommand) heading() string {
+	if bc.target.name != "" {
+		return bc.target.name
+	}
+	return bc.target.command
+}
+
+// parametersHeading returns the values of the scanned parameters to print after the heading, if any.
+func (bc *benchmarkCommand) parametersHeading() string {
+	if len(bc.target.parameters) == 0 {
+		return ""
+	}
+	return " (" + internal.FormatParameters(bc.target.parameters) + ")"
+}
+
+// fail reports the error and marks the command as failed if err is a [failedProcessError], except for the
+// commands stopped by an interrupt. Returns whether err is a [failedProcessError].
+func (bc *benchmarkCommand) fail(err error, progress *benchmarkProgress) bool {
+	var processErr *failedProcessError
+	if !errors.As(err, &processErr) {
+		return false
+	}
+	if errors.Is(processErr.err, errInterrupted) {
+		// the iteration was stopped by an interrupt, the command didn't fail
+		return true
+	}
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	// with concurrent slots, only the first failure is reported
+	if !bc.failed {
+		progress.clear()
+		processErr.handle()
+	}
+	bc.failed = true
+	bc.runsData = nil
+	return true
+}
+
+// benchmarkProgress shows the progress of a [Benchmark], either as a progress bar or by logging every run.
+type benchmarkProgress struct {
+	opts *BenchmarkOptions
+	bar  *progressbar.ProgressBar
+	// whether several commands are benchmarked together, in which case they're told apart in the logs
+	multiple  bool
+	completed int
+	// set when the benchmark is time-boxed, the bar then shows the time spent (in milliseconds) instead of the runs
+	timed   bool
+	started time.Time
+	mu      sync.Mutex
+}
+
+func newBenchmarkProgress(opts *BenchmarkOptions, total int, multiple bool) *benchmarkProgress {
+	progress := &benchmarkProgress{opts: opts, multiple: multiple, started: time.Now()}
+	if opts.duration > 0 {
+		progress.timed = true
+		total = int(opts.duration.Milliseconds())
+	} else if opts.precision > 0 {
+		// there's no telling how many runs it takes, a spinner counts them instead
+		total = -1
+	}
+	if opts.verbose {
+		return progress
 	}
 	descriptionMap := map[benchmarkMode]string{
 		shellMode:  "Measuring shell spawn time",
 		warmupMode: "Performing warmup runs",
 		mainMode:   "Performing benchmark runs",
 	}
-	var processErr *failedProcessError
-	// dont ignore errors in prepare and cleanup command
-	prepareRunOpts := RunOptions{
-		command:     opts.prepareCmd,
-		verbose:     opts.verbose,
-		ignoreError: false,
-		timeout:     opts.timeout,
+	description, ok := descriptionMap[opts.mode]
+	if !ok {
+		// used internally, ok to panic
+		panic(fmt.Sprintf("invalid mode passed to benchmark: %v", opts.mode))
 	}
-	runOpts := RunOptions{
-		command:     opts.command,
-		verbose:     opts.verbose,
-		ignoreError: opts.ignoreError,
-		timeout:     opts.timeout,
+	pbarOptions := []progressbar.Option{
+		progressbar.OptionClearOnFinish(),
+		progressbar.OptionSetDescription("[magenta]" + description + "[reset]"),
+		progressbar.OptionSetPredictTime(true),
+		progressbar.OptionSetTheme(progressbar.Theme{
+			Saucer:        "[green]█[reset]",
+			SaucerPadding: " ",
+			BarStart:      "|",
+			BarEnd:        "|",
+		}),
 	}
-	cleanupRunOpts := RunOptions{
-		command:     opts.cleanupCmd,
-		verbose:     opts.verbose,
-		ignoreError: false,
-		timeout:     opts.timeout,
+	if NoColor {
+		pbarOptions = append(pbarOptions, progressbar.OptionEnableColorCodes(true))
 	}
-	// todo refactor this code to eliminate code repetition
+	progress.bar = progressbar.NewOptions(total, pbarOptions...)
+	return progress
+}
 
-	// * looping for given runs
-	if opts.verbose {
-		word, ok := wordMap[opts.mode]
+// starting logs the run which is about to be executed, in verbose mode.
+func (bp *benchmarkProgress) starting(bc *benchmarkCommand, iteration int) {
+	if !bp.opts.verbose {
+		return
+	}
+	wordMap := map[benchmarkMode]string{
+		shellMode:  "shell",
+		warmupMode: "warmup",
+		mainMode:   "iteration",
+	}
+	word, ok := wordMap[bp.opts.mode]
+	if !ok {
+		panic(fmt.Sprintf("invalid mode passed to benchmark: %v", bp.opts.mode))
+	}
+	text := fmt.Sprintf("Running "+word+" %d", iteration)
+	if bp.multiple {
+		text += " of " + bc.target.command
+	}
+	internal.Log("purple", "***********\n"+text+"\n***********")
+}
+
+// finished advances the progress bar after a run, showing the current estimate of the command's mean.
+// Must be called with the lock of the command held.
+func (bp *benchmarkProgress) finished(bc *benchmarkCommand) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	bp.completed++
+	if bp.bar == nil {
+		return
+	}
+	// there's no estimate as long as all the runs were discarded
+	if bp.opts.mode == mainMode && len(bc.runsData) != 0 {
+		var estimate string
+		if bp.opts.precision > 0 {
+			times := bc.elapsedTimes()
+			value, _, _ := internal.ConfidenceInterval(times, bp.opts.precisionStat)
+			estimate = internal.DurationFromNumber(value, time.Microsecond).String()
+			if precision := internal.RelativePrecision(times, bp.opts.precisionStat); !math.IsInf(precision, 0) {
+				estimate += fmt.Sprintf(" ± %.2f%% [reset](target: ± %.2f%%)", precision*100, bp.opts.precision*100)
+			}
+		} else {
+			estimate = internal.DurationFromNumber(internal.CalculateAverage(bc.elapsedTimes()), time.Microsecond).String()
+		}
+		if bp.multiple {
+			estimate += " [reset](" + bc.target.command + ")"
+		}
+		bp.bar.Describe(fmt.Sprintf("[magenta]Current estimate: [green]%s[reset]", estimate))
+	}
+	if bp.timed {
+		bp.tick()
+	} else {
+		bp.bar.Add(1)
+	}
+}
+
+// tick advances the bar of a time-boxed benchmark to the time spent so far.
+// Must be called with the lock held.
+func (bp *benchmarkProgress) tick() {
+	// the bar is kept short of its end, which would clear it, until the benchmark is done
+	spent := min(time.Since(bp.started).Milliseconds(), bp.opts.duration.Milliseconds()-1)
+	bp.bar.Set64(spent)
+}
+
+// ticks advances the bar of a time-boxed benchmark regularly, even while long runs are executing,
+// until stop is closed.
+func (bp *benchmarkProgress) ticks(stop <-chan struct{}) {
+	if bp.bar == nil {
+		return
+	}
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			bp.mu.Lock()
+			bp.tick()
+			bp.mu.Unlock()
+		}
+	}
+}
+
+// setTotal changes the total number of runs, once it's known.
+func (bp *benchmarkProgress) setTotal(total int) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	if bp.bar == nil || bp.timed {
+		return
+	}
+	bp.bar.Reset()
+	bp.bar.ChangeMax(total)
+	bp.bar.Add(bp.completed)
+}
+
+// clear removes the progress bar from the terminal, e.g. before printing an error.
+func (bp *benchmarkProgress) clear() {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	if bp.bar != nil && !bp.bar.IsFinished() {
+		bp.bar.Clear()
+	}
+}
+
+// isolate copies the fixture to a new temporary directory and returns its path, which must be removed
+// afterwards even if an error is returned.
+func isolate(fixture string) (string, error) {
+	dir, err := os.MkdirTemp("", "atomic-isolate-")
+	if err != nil {
+		return "", &failedProcessError{command: []string{fixture}, err: err, where: "isolating the run"}
+	}
+	if err := internal.CopyTree(fixture, dir); err != nil {
+		return dir, &failedProcessError{command: []string{fixture}, err: err, where: "isolating the run"}
+	}
+	return dir, nil
+}
+
+// runIteration executes a single run of the command on the given slot, preceded by its prepare command and
+// followed by its cleanup command, in a fresh copy of its fixture if the runs are isolated. A failed run is
+// retried up to `opts.retries` times, then discarded if the failure budget allows it. It returns the result of
+// the run (nil if it was discarded) and the total duration of the iteration, or false if the command failed.
+func runIteration(bc *benchmarkCommand, opts *BenchmarkOptions, progress *benchmarkProgress, slot int) (*RunResult, time.Duration, bool) {
+	bc.mu.Lock()
+	bc.started++
+	progress.starting(bc, bc.started)
+	bc.mu.Unlock()
+	var total time.Duration
+	for attempt := 0; ; attempt++ {
+		runResult, elapsed, runErr, ok := attemptIteration(bc, opts, progress, slot)
+		total += elapsed
 		if !ok {
-			// used internally, ok to panic
-			panic(fmt.Sprintf("invalid mode passed to benchmark: %v", opts.mode))
+			return nil, 0, false
 		}
-		startI := 1
-		if opts.runs < 0 {
-			prepareResult := emptyRunResult()
-			cleanupResult := emptyRunResult()
-			if opts.executePrepareCmd {
-				prepareResult = RunCommand(&prepareRunOpts)
-				if errors.As(prepareResult.err, &processErr) {
-					processErr.handle()
-					return nil, true
-				}
+		if runErr == nil {
+			return runResult, total, true
+		}
+		if attempt < opts.retries {
+			if opts.verbose {
+				internal.Log("yellow", fmt.Sprintf("Retrying the run (%s)", failureReason(runErr)))
 			}
-			startI = 2
-			singleRunResult := RunCommand(&runOpts)
-			if errors.As(singleRunResult.err, &processErr) {
-				processErr.handle()
-				return nil, true
+			bc.mu.Lock()
+			bc.retries++
+			bc.mu.Unlock()
+			continue
+		}
+		if bc.discard(runErr, opts, progress) {
+			return nil, total, true
+		}
+		return nil, 0, false
+	}
+}
+
+// attemptIteration executes the iteration once, see [runIteration]. A failed run is returned as `runErr`
+// when the failure may be tolerated by retrying or discarding the run, in which case the cleanup command
+// is executed anyway, otherwise the command fails and false is returned.
+func attemptIteration(bc *benchmarkCommand, opts *BenchmarkOptions, progress *benchmarkProgress, slot int) (runResult *RunResult, total time.Duration, runErr *failedProcessError, ok bool) {
+	env := bc.env
+	if opts.jobs > 1 {
+		if env == nil {
+			env = os.Environ()
+		}
+		env = append(slices.Clip(env), "ATOMIC_SLOT="+strconv.Itoa(slot))
+	}
+	dir := bc.dir
+	if bc.fixture != "" {
+		// the copy isn't measured, like the prepare command
+		copyStart := time.Now()
+		isolated, err := isolate(bc.fixture)
+		if isolated != "" {
+			defer os.RemoveAll(isolated)
+		}
+		if bc.fail(err, progress) {
+			return nil, 0, nil, false
+		}
+		total += time.Since(copyStart)
+		dir = isolated
+	}
+	// dont ignore errors in prepare and cleanup command
+	if bc.prepareCmd != nil {
+		prepareResult := RunCommand(&RunOptions{
+			command: bc.prepareCmd,
+			output:  verboseOutput(opts.verbose),
+			timeout: opts.timeout,
+			env:     env,
+			dir:     dir,
+			stop:    interrupts.stopped,
+		})
+		if bc.fail(prepareResult.err, progress) {
+			return nil, 0, nil, false
+		}
+		total += prepareResult.elapsed
+	}
+
+	started := time.Now()
+	if opts.persistentShell != nil {
+		runResult = bc.runInShell(slot, opts, env, dir)
+	} else {
+		runResult = RunCommand(&RunOptions{
+			command:           bc.command,
+			output:            opts.output,
+			ignoreError:       opts.ignoreError,
+			acceptedExitCodes: opts.acceptedExitCodes,
+			timeout:           opts.timeout,
+			recordTimeout:     opts.onTimeout == recordOnTimeout,
+			input:             opts.input,
+			perfCounters:      opts.perfCounters,
+			exactMemory:       true,
+			cpus:              bc.cpus,
+			env:               env,
+			dir:               dir,
+			stop:              interrupts.stopped,
+		})
+	}
+	ended := time.Now()
+	var processErr *failedProcessError
+	if errors.As(runResult.err, &processErr) && processErr.where == "execution" && !errors.Is(processErr.err, errInterrupted) && (opts.retries > 0 || !opts.maxFailures.IsZero()) {
+		runErr = processErr
+	} else if bc.fail(runResult.err, progress) {
+		return nil, 0, nil, false
+	}
+	total += runResult.elapsed
+	runResult.elapsed -= opts.shellCalibration.elapsed
+	runResult.user -= opts.shellCalibration.user
+	runResult.system -= opts.shellCalibration.system
+
+	bc.mu.Lock()
+	if bc.failed {
+		// another slot's run of the command failed in the meantime
+		bc.mu.Unlock()
+		return nil, 0, nil, false
+	}
+	if runErr == nil {
+		if len(bc.runsData) == 0 || started.Before(bc.firstStart) {
+			bc.firstStart = started
+		}
+		if ended.After(bc.lastEnd) {
+			bc.lastEnd = ended
+		}
+		bc.runsData = append(bc.runsData, runResult)
+		progress.finished(bc)
+	}
+	bc.mu.Unlock()
+
+	if bc.cleanupCmd != nil {
+		cleanupResult := RunCommand(&RunOptions{
+			command: bc.cleanupCmd,
+			output:  verboseOutput(opts.verbose),
+			timeout: opts.timeout,
+			env:     env,
+			dir:     dir,
+			stop:    interrupts.stopped,
+		})
+		if bc.fail(cleanupResult.err, progress) {
+			return nil, 0, nil, false
+		}
+		total += cleanupResult.elapsed
+	}
+	return runResult, total, runErr, true
+}
+
+// marks the commands as interrupted if atomic was, returns whether it was
+func markInterrupted(commands []*benchmarkCommand) bool {
+	if !interrupts.interrupted() {
+		return false
+	}
+	for _, bc := range commands {
+		bc.interrupted = true
+	}
+	return true
+}
+
+// Benchmark runs all the given commands as per the given opts, in the order given by `opts.order`.
+// The results of the runs are stored in the `runsData` of every command, in microseconds.
+// Commands which have already failed are skipped, and commands which fail are marked as such.
+// An interrupt stops the benchmark, the commands are marked as interrupted then and keep the runs completed so far.
+func Benchmark(commands []*benchmarkCommand, opts BenchmarkOptions) {
+	active := internal.FilterFunc(func(bc *benchmarkCommand) bool { return !bc.failed }, commands)
+	if len(active) == 0 {
+		return
+	}
+	for _, bc := range active {
+		bc.runsData = nil
+		bc.started = 0
+		bc.planned = 0
+		bc.retries = 0
+		bc.discarded = nil
+		bc.interrupted = false
+		bc.firstStart = time.Time{}
+		bc.lastEnd = time.Time{}
+	}
+	if markInterrupted(active) {
+		return
+	}
+	runs := make([]int, len(active))
+	for i := range runs {
+		runs[i] = opts.runs
+		if opts.runs < 0 {
+			runs[i] = 1
+		}
+		if !opts.adaptive() {
+			active[i].planned = runs[i]
+		}
+	}
+	if sum(runs) == 0 {
+		return
+	}
+	progress := newBenchmarkProgress(&opts, sum(runs), len(active) > 1)
+	defer progress.clear()
+	defer markInterrupted(active)
+
+	// automatically determine runs from a single run of every command, without contention
+	if opts.runs < 0 && !opts.adaptive() {
+		for _, i := range schedule(runs, opts.order, opts.rng) {
+			if interrupts.interrupted() {
+				break
 			}
-			if opts.executeCleanupCmd {
-				cleanupResult = RunCommand(&cleanupRunOpts)
-				if errors.As(cleanupResult.err, &processErr) {
-					processErr.handle()
-					return nil, true
-				}
+			if _, total, ok := runIteration(active[i], &opts, progress, 1); ok {
+				runs[i] = determineRuns(total) - 1
+				active[i].planned = runs[i] + 1
+			} else {
+				runs[i] = 0
 			}
-			opts.runs = determineRuns(singleRunResult.elapsed + prepareResult.elapsed + cleanupResult.elapsed)
-			singleRunResult.elapsed -= opts.shellCalibration.elapsed
-			singleRunResult.user -= opts.shellCalibration.user
-			singleRunResult.system -= opts.shellCalibration.system
-			runsData = append(runsData, singleRunResult)
 		}
-		for i := startI; i <= opts.runs; i++ {
-			internal.Log("purple", fmt.Sprintf("***********\nRunning "+word+" %d\n***********", i))
+		progress.setTotal(progress.completed + sum(runs))
+	}
 
-			// dont output prepare command execution
-			if opts.executePrepareCmd {
-				prepareResult := RunCommand(&prepareRunOpts)
-				if errors.As(prepareResult.err, &processErr) {
-					processErr.handle()
-					return nil, true
+	// the slots take the scheduled runs one by one, keeping `opts.jobs` runs in flight
+	next := make(chan int)
+	// holds a token for every run in flight, so that adaptive benchmarks decide on a run once a slot is free
+	var inFlight chan struct{}
+	if opts.adaptive() {
+		inFlight = make(chan struct{}, max(opts.jobs, 1))
+	}
+	var wg sync.WaitGroup
+	for slot := 1; slot <= max(opts.jobs, 1); slot++ {
+		wg.Add(1)
+		go func(slot int) {
+			defer wg.Done()
+			for i := range next {
+				if !active[i].isFailed() && !interrupts.interrupted() {
+					runIteration(active[i], &opts, progress, slot)
+				}
+				if inFlight != nil {
+					<-inFlight
 				}
 			}
-			runResult := RunCommand(&runOpts)
-			if errors.As(runResult.err, &processErr) {
-				processErr.handle()
-				return nil, true
+		}(slot)
+	}
+	if opts.adaptive() {
+		stop := make(chan struct{})
+		if opts.duration > 0 {
+			go progress.ticks(stop)
+		}
+		scheduleAdaptive(active, next, inFlight, &opts)
+		close(stop)
+	} else {
+		for _, i := range schedule(runs, opts.order, opts.rng) {
+			if interrupts.interrupted() {
+				break
 			}
-			runResult.elapsed -= opts.shellCalibration.elapsed
-			runResult.user -= opts.shellCalibration.user
-			runResult.system -= opts.shellCalibration.system
-			runsData = append(runsData, runResult)
+			next <- i
+		}
+	}
+	close(next)
+	wg.Wait()
+}
 
-			if opts.executeCleanupCmd {
-				cleanupResult := RunCommand(&cleanupRunOpts)
-				if errors.As(cleanupResult.err, &processErr) {
-					processErr.handle()
-					return nil, true
-				}
+// scheduleAdaptive sends the commands' runs to the slots in rounds, until the time budget is spent or the
+// precision target of every command is reached, with every command being run at least [MinRuns] times.
+// Commands drop out once they've been run [MaxRuns] times. Every run is decided on once a slot is free
+// (a token could be put in inFlight), from the results of all the runs completed by then.
+func scheduleAdaptive(commands []*benchmarkCommand, next chan<- int, inFlight chan struct{}, opts *BenchmarkOptions) {
+	started := time.Now()
+	dispatched := make([]int, len(commands))
+	needsRun := func(i int) bool {
+		switch {
+		case commands[i].isFailed() || dispatched[i] >= MaxRuns || interrupts.interrupted():
+			return false
+		case dispatched[i] < MinRuns:
+			return true
+		case opts.duration > 0:
+			return time.Since(started) < opts.duration
+		default:
+			return commands[i].precision(opts.precisionStat) > opts.precision
+		}
+	}
+	for {
+		round := make([]int, len(commands))
+		for i := range commands {
+			if needsRun(i) {
+				round[i] = 1
 			}
 		}
+		if sum(round) == 0 {
+			if len(inFlight) == 0 {
+				return
+			}
+			// the runs in flight may widen a confidence interval again, wait for them before deciding
+			for j := 0; j < cap(inFlight); j++ {
+				inFlight <- struct{}{}
+			}
+			for j := 0; j < cap(inFlight); j++ {
+				<-inFlight
+			}
+			continue
+		}
+		for _, i := range schedule(round, opts.order, opts.rng) {
+			inFlight <- struct{}{}
+			if !needsRun(i) {
+				<-inFlight
+				continue
+			}
+			dispatched[i]++
+			next <- i
+		}
+	}
+}
 
-	} else {
-		description, ok := descriptionMap[opts.mode]
-		if !ok {
-			panic(fmt.Sprintf("invalid mode passed to benchmark: %v", opts.mode))
-		}
-		pbarOptions := []progressbar.Option{
-			progressbar.OptionClearOnFinish(),
-			progressbar.OptionSetDescription("[magenta]" + description + "[reset]"),
-			progressbar.OptionSetPredictTime(true),
-			progressbar.OptionSetTheme(progressbar.Theme{
-				Saucer:        "[green]█[reset]",
-				SaucerPadding: " ",
-				BarStart:      "|",
-				BarEnd:        "|",
-			}),
-		}
-		if NoColor {
-			pbarOptions = append(pbarOptions, progressbar.OptionEnableColorCodes(true))
-		}
-		barMax := opts.runs
-		if barMax < 0 {
-			barMax = 1
-		}
-		bar := progressbar.NewOptions(
-			barMax, pbarOptions...,
-		)
-		startI := 1
-		prepareResult := emptyRunResult()
-		cleanupResult := emptyRunResult()
-
-		// automatically determine runs
-		autoRuns := opts.runs < 0
-		if autoRuns {
-			startI = 2
-			if opts.executePrepareCmd {
-				prepareResult = RunCommand(&prepareRunOpts)
-				if errors.As(prepareResult.err, &processErr) {
-					processErr.handle()
-					return nil, true
-				}
-			}
-			singleRunResult := RunCommand(&runOpts)
-			if errors.As(singleRunResult.err, &processErr) {
-				processErr.handle()
-				return nil, true
-			}
-
-			if opts.executeCleanupCmd {
-				cleanupResult = RunCommand(&cleanupRunOpts)
-				if errors.As(cleanupResult.err, &processErr) {
-					processErr.handle()
-					return nil, true
-				}
-			}
-			opts.runs = determineRuns(singleRunResult.elapsed + prepareResult.elapsed + cleanupResult.elapsed)
-			singleRunResult.elapsed -= opts.shellCalibration.elapsed
-			singleRunResult.user -= opts.shellCalibration.user
-			singleRunResult.system -= opts.shellCalibration.system
-			bar.Reset()
-			bar.ChangeMax(opts.runs)
-			bar.Add(1)
-			runsData = append(runsData, singleRunResult)
-		}
-		for i := startI; i <= opts.runs; i++ {
-			// run the prepareCmd first
-			// dont ignore errors in prepare command execution, dont output it either
-			if opts.executePrepareCmd {
-				prepareResult = RunCommand(&prepareRunOpts)
-				if errors.As(prepareResult.err, &processErr) {
-					processErr.handle()
-					return nil, true
-				}
-			}
-			runResult := RunCommand(&runOpts)
-			if errors.As(runResult.err, &processErr) {
-				bar.Clear()
-				processErr.handle()
-				return nil, true
-			}
-			runResult.elapsed -= opts.shellCalibration.elapsed
-			runResult.user -= opts.shellCalibration.user
-			runResult.system -= opts.shellCalibration.system
-			runsData = append(runsData, runResult)
-
-			if opts.mode == mainMode {
-				bar.Describe(
-					fmt.Sprintf("[magenta]Current estimate: [green]%s[reset]",
-						internal.DurationFromNumber(
-							internal.CalculateAverage(
-								internal.MapFunc[[]*RunResult, []float64](func(r *RunResult) float64 { return float64(r.elapsed.Microseconds()) },
-									runsData[:]),
-							), time.Microsecond).String(),
-					),
-				)
-			}
-			bar.Add(1)
-			if opts.executeCleanupCmd {
-				cleanupResult = RunCommand(&cleanupRunOpts)
-				if errors.As(cleanupResult.err, &processErr) {
-					processErr.handle()
-					return nil, true
-				}
-			}
-		}
-	}
-	return runsData, false
-}
-
-// todo parameter scan
-// this is how imagine the parameter scan would be given
-// --parameter-scan "variable=start:end:step;var2=[val1,val2,val3]"
+func sum(values []int) int {
+	total := 0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
 
-func main() {
-	internal.Log("white", fmt.Sprintf("%v %v\n", NAME, VERSION))
+// benchmarkTarget is a single command to benchmark along with the options that are specific to it,
+// such as its name, its prepare and cleanup commands which are executed around every run and its setup and
+// conclude commands which are executed once before and after all the runs. Empty commands are not executed.
+// `cpus` is the list of cpus the command is restricted to, empty if it isn't.
+// `env` are the `KEY=VALUE` environment variables set for all the commands, the per-command ones last.
+// `cwd` is the working directory of all the commands, and `isolate` the fixture directory copied for every iteration.
+type benchmarkTarget struct {
+	// the command (or its name, if given) as it was given, before expanding the placeholders
+	template   string
+	command    string
+	name       string
+	prepare    string
+	cleanup    string
+	setup      string
+	conclude   string
+	cpus       string
+	env        []string
+	cwd        string
+	isolate    string
+	parameters map[string]string
+}
+
+// substitute returns the target with the given placeholders substituted in the command, the prepare, cleanup,
+// setup and conclude commands, its cpus, environment, working directory and fixture.
+func (bt benchmarkTarget) substitute(values map[string]string) benchmarkTarget {
+	bt.command = internal.SubstituteParameters(bt.command, values)
+	bt.prepare = internal.SubstituteParameters(bt.prepare, values)
+	bt.cleanup = internal.SubstituteParameters(bt.cleanup, values)
+	bt.setup = internal.SubstituteParameters(bt.setup, values)
+	bt.conclude = internal.SubstituteParameters(bt.conclude, values)
+	bt.cpus = internal.SubstituteParameters(bt.cpus, values)
+	bt.cwd = internal.SubstituteParameters(bt.cwd, values)
+	bt.isolate = internal.SubstituteParameters(bt.isolate, values)
+	bt.env = internal.MapFunc[[]string, []string](func(variable string) string { return internal.SubstituteParameters(variable, values) }, bt.env)
+	return bt
+}
+
+// expand returns the target with the `{variable}` placeholders of a parameter scan substituted, in its name too.
+func (bt benchmarkTarget) expand(values map[string]string) benchmarkTarget {
+	bt = bt.substitute(values)
+	bt.name = internal.SubstituteParameters(bt.name, values)
+	bt.parameters = values
+	return bt
+}
+
+// expandName returns the target with the `{name}` placeholder substituted with its name, if it was given one.
+// A scanned variable called name takes precedence, since the scanned variables are substituted first.
+func (bt benchmarkTarget) expandName() benchmarkTarget {
+	if bt.name == "" {
+		return bt
+	}
+	return bt.substitute(map[string]string{"name": bt.name})
+}
+
+// expandTargets expands each given target with every given combination of the scanned parameters,
+// in the order the targets are given, and then with its name.
+func expandTargets(targets []benchmarkTarget, combinations []map[string]string) []benchmarkTarget {
+	if len(combinations) == 0 {
+		return internal.MapFunc[[]benchmarkTarget, []benchmarkTarget](benchmarkTarget.expandName, targets)
+	}
+	var expanded []benchmarkTarget
+	for _, target := range targets {
+		for _, values := range combinations {
+			expanded = append(expanded, target.expand(values).expandName())
+		}
+	}
+	return expanded
+}
+
+// getPositionalFlag returns all the values of a repeatable flag which are matched positionally to
+// the given commands. A single value is shared by all the commands, otherwise there must be exactly
+// as many values as the commands. Missing values are returned as empty strings.
+func getPositionalFlag(nCommands int, names ...string) ([]string, error) {
+	values := internal.RepeatedFlagValues(os.Args[1:], names...)
+	switch len(values) {
+	case 0:
+		return make([]string, nCommands), nil
+	case 1:
+		shared := make([]string, nCommands)
+		for i := range shared {
+			shared[i] = values[0]
+		}
+		return shared, nil
+	case nCommands:
+		return values, nil
+	default:
+		return nil, fmt.Errorf("the --%s flag was given %d times for %d commands, it must be given either once or once for every command", names[0], len(values), nCommands)
+	}
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == measureHelperArg {
+		runMeasureHelper(os.Args[2:])
+		return
+	}
+	internal.Log("white", fmt.Sprintf("%v %v\n", NAME, VERSION))
 
 	updateCh := make(chan string, 1)
 	go internal.CheckForUpdates(VERSION, &updateCh)
-	defer fmt.Println(<-updateCh)
+	// printed once the benchmark is over
+	update := <-updateCh
 
 	// * basic configuration
 	commando.
@@ -494,19 +1261,54 @@ func main() {
 		AddFlag("min,m", "Minimum number of runs to perform.", commando.Int, MinRuns).
 		AddFlag("max,M", "Maximum number of runs to perform.", commando.Int, MaxRuns).
 		AddFlag("runs,r", "The number of runs to perform", commando.Int, -1).
+		AddFlag("duration,d", "The time to keep benchmarking each command for, like 30s or 2m, regardless of the time a run takes. The --min and --max flags still bound the number of runs.", commando.String, dummyDefault).
 		AddFlag("warmup,w", "The number of warmup runs to perform.", commando.Int, 0).
-		AddFlag("prepare,p", "The command to execute once before every run.", commando.String, dummyDefault).
-		AddFlag("cleanup,c", "The command to execute once after every run.", commando.String, dummyDefault).
+		AddFlag("precision", "Keep running every command until the 95% confidence interval of its run time is within this relative width of the estimate, like 1% or 0.01. The --min and --max flags still bound the number of runs.", commando.String, dummyDefault).
+		AddFlag("precision-stat", "The estimate whose confidence interval the --precision flag narrows down, either mean or median.", commando.String, "mean").
+		AddFlag("jobs,j", "The number of runs to keep executing concurrently, to benchmark the commands under contention.", commando.Int, 1).
+		AddFlag("order", "The order in which the runs of several commands are executed: sequential (one command after another), interleaved (the commands take turns) or shuffled (the commands take turns in a random order).", commando.String, "sequential").
+		AddFlag("seed", "The seed of the random order of the runs with --order shuffled. Defaults to a random seed.", commando.String, dummyDefault).
+		AddFlag("prepare,p", "The command to execute once before every run. Can be repeated to give every command its own prepare command, matched in order.", commando.String, dummyDefault).
+		AddFlag("cleanup,c", "The command to execute once after every run. Can be repeated to give every command its own cleanup command, matched in order.", commando.String, dummyDefault).
+		AddFlag("command-name,n", "Name to use for a command in summaries and exports, may contain {variable} placeholders. Can be repeated, names are matched to the commands in order.", commando.String, dummyDefault).
+		AddFlag("setup", "The command to execute once before all the runs (including warmup) of a command. Can be repeated, matched to the commands in order.", commando.String, dummyDefault).
+		AddFlag("conclude", "The command to execute once after all the runs of a command. Can be repeated, matched to the commands in order.", commando.String, dummyDefault).
+		AddFlag("cpus", "Comma separated list of cpus (or ranges of cpus, like 2-3) to restrict the benchmarked commands to. Can be repeated, matched to the commands in order. Only available on linux.", commando.String, dummyDefault).
+		AddFlag("env", "An environment variable to set for all the commands, as KEY=VALUE. Can be repeated.", commando.String, dummyDefault).
+		AddFlag("command-env", "Space separated KEY=VALUE environment variables to set for a command, with the values quoted like in a shell. Can be repeated, matched to the commands in order.", commando.String, dummyDefault).
+		AddFlag("clean-env", "Execute the commands in a minimal environment (PATH, LC_ALL=C and TZ=UTC) instead of the environment of atomic, for reproducible benchmarks.", commando.Bool, false).
+		AddFlag("cwd", "The working directory of a command and of its prepare, cleanup, setup and conclude commands. Can be repeated, matched to the commands in order.", commando.String, dummyDefault).
+		AddFlag("isolate", "A fixture directory which is copied to a fresh temporary directory before every iteration, in which the prepare command, the run and the cleanup command are executed. The copy isn't measured. Can be repeated, matched to the commands in order.", commando.String, dummyDefault).
+		AddFlag("runner-cpus", "Comma separated list of cpus (or ranges of cpus) to restrict atomic itself to, preferably different from those given to --cpus. Only available on linux.", commando.String, dummyDefault).
+		AddFlag("parameter-scan", "Benchmark the commands for every value of the given variables, used as {variable} in the commands, e.g. \"threads=1:8:1;mode=[fast,slow]\".", commando.String, dummyDefault).
+		AddFlag("parameter-include", "Only benchmark the parameter combinations matching any of the given rules, e.g. \"threads=1,mode=fast;threads=8\".", commando.String, dummyDefault).
+		AddFlag("parameter-exclude", "Skip the parameter combinations matching any of the given rules, e.g. \"threads=1,mode=slow\".", commando.String, dummyDefault).
+		AddFlag("pivot", "Show the results of a parameter scan as a matrix, with the values of one variable on the rows and another on the columns, e.g. \"threads,mode\".", commando.String, dummyDefault).
+		AddFlag("input", "Path of a file to feed to the standard input of the benchmarked commands, read from the start in every run.", commando.String, dummyDefault).
+		AddFlag("input-bytes", "Feed the given number of generated bytes (e.g. 512, 64K, 10M) to the standard input of the benchmarked commands.", commando.String, dummyDefault).
+		AddFlag("input-fill", "The bytes to generate for the --input-bytes flag, either random (seeded, so identical across runs) or zero.", commando.String, "random").
 		AddFlag("ignore-error,I", "Ignore if the process returns a non-zero return code", commando.Bool, false).
+		AddFlag("ignore-exit-codes", "Comma separated list of non-zero exit codes which don't make a run fail, e.g. 1,3.", commando.String, dummyDefault).
+		AddFlag("retries", "The number of times a failed run is retried before it's considered failed.", commando.Int, 0).
+		AddFlag("max-failures", "The number (like 3) or percentage (like 5%) of runs of a command which may fail and are discarded, before the command fails.", commando.String, dummyDefault).
 		AddFlag("shell,s", "Whether to use shell to execute the given command.", commando.Bool, false).
 		AddFlag("shell-path", "Path to the shell to use.", commando.String, defaultShellValue).
+		AddFlag("argv", "Give every benchmarked command as a JSON array of its arguments, like [\"grep\", \"-r\", \"a b\"], which are executed as is without any splitting or shell.", commando.Bool, false).
+		AddFlag("shell-mode", "How to execute the commands through the shell: spawn (a shell for every run, whose spawn time is subtracted) or persistent (a single long-lived POSIX shell executing all the runs, implies -s/--shell).", commando.String, string(spawnExecution)).
+		AddFlag("shell-init", "A script the persistent shell evaluates before the runs, e.g. to define the functions and aliases to benchmark.", commando.String, dummyDefault).
+		AddFlag("shell-rc", "Let the shell read its startup files (like .bashrc or the powershell profile), which are skipped by default.", commando.Bool, false).
 		AddFlag("timeout,t", "The timeout for a single command.", commando.String, LargestDurationString).
+		AddFlag("on-timeout", "What to do with a run which exceeds the timeout: fail (the benchmark of the command fails) or record (the run is kept as a censored run, lasting the timeout).", commando.String, string(failOnTimeout)).
 		AddFlag("verbose,V", "Enable verbose output.", commando.Bool, false).
+		AddFlag("output", "What to do with the output of the benchmarked commands: null (discard it), pipe (read and count it), inherit (show it) or a path to write it to. Defaults to inherit with -V/--verbose, null otherwise.", commando.String, dummyDefault).
 		AddFlag("no-color", "Disable colored output.", commando.Bool, false).
 		AddFlag("export,e", "Comma separated list of benchmark export formats, including json, text, csv and markdown.", commando.String, "none").
 		AddFlag("filename,f", "The filename to use in exports.", commando.String, "atomic-summary").
 		AddFlag("time-unit,u", "The time unit to use for exported results. Must be one of ns, us, ms, s, m, h.", commando.String, "ms").
 		AddFlag("plot", "Comma separated list of plot types. Use all if you want to draw all the plots, or you can specify hist/histogram, box/boxplot, errorbar, bar, bubble.", commando.String, "none").
+		AddFlag("metrics", "Comma separated list of optional metrics to collect for every run. Available: rusage (context switches, page faults and block I/O).", commando.String, dummyDefault).
+		AddFlag("perf-counters", "Comma separated list of perf counters to measure every run with, e.g. instructions,cycles,branch-misses,task-clock. Only available on linux.", commando.String, dummyDefault).
+		AddFlag("relative-metric", "The metric to compare the commands by in the summary, either time or memory.", commando.String, "time").
 		AddFlag("outlier-threshold", "Minimum number of runs to be outliers for the outlier warning to be displayed, in percentage.", commando.String, "0").
 		SetAction(func(args map[string]commando.ArgValue, flags map[string]commando.FlagValue) {
 			// * getting args and flag values
@@ -535,6 +1337,67 @@ func main() {
 				return
 			}
 
+			durationString, e := flags["duration"].GetString()
+			if e != nil {
+				internal.Log("red", "Application error: cannot parse flag values.")
+				return
+			}
+			var duration time.Duration
+			if durationString != dummyDefault {
+				duration, e = time.ParseDuration(durationString)
+				if e != nil || duration <= 0 {
+					internal.Log("red", "invalid duration: "+durationString)
+					return
+				}
+				if runs > 0 {
+					internal.Log("red", "the --runs and --duration flags cannot be used together.")
+					return
+				}
+				// the default minimum is meant for the runs determined from a single run,
+				// a time-boxed benchmark only needs a single run unless told otherwise
+				if len(internal.RepeatedFlagValues(os.Args[1:], "min", "m")) == 0 {
+					MinRuns = 1
+				}
+				if MinRuns > MaxRuns {
+					internal.Log("red", "the minimum number of runs cannot be greater than the maximum.")
+					return
+				}
+			}
+
+			precisionString, e := flags["precision"].GetString()
+			if e != nil {
+				internal.Log("red", "Application error: cannot parse flag values.")
+				return
+			}
+			var precision float64
+			if precisionString != dummyDefault {
+				precision, e = internal.ParsePrecision(precisionString)
+				if e != nil {
+					internal.Log("red", e.Error())
+					return
+				}
+				if runs > 0 || duration > 0 {
+					internal.Log("red", "the --precision flag cannot be used together with the --runs or --duration flags.")
+					return
+				}
+				// a confidence interval needs at least two runs
+				MinRuns = max(MinRuns, 2)
+				if MinRuns > MaxRuns {
+					internal.Log("red", "the minimum number of runs cannot be greater than the maximum.")
+					return
+				}
+			}
+			precisionStatString, e := flags["precision-stat"].GetString()
+			if e != nil {
+				internal.Log("red", "Application error: cannot parse flag values.")
+				return
+			}
+			precisionStat, e := internal.ParseStatistic(precisionStatString)
+			if e != nil {
+				internal.Log("red", e.Error())
+				return
+			}
+
 			warmupRuns, e := flags["warmup"].GetInt()
 			if e != nil {
 				internal.Log("red", "The number of runs must be an integer!")
@@ -548,6 +1411,20 @@ func main() {
 				return
 			}
 
+			outputString, e := flags["output"].GetString()
+			if e != nil {
+				internal.Log("red", "Application error: cannot parse flag values.")
+				return
+			}
+			output := verboseOutput(verbose)
+			if outputString != dummyDefault {
+				output, e = parseOutputMode(outputString)
+				if e != nil {
+					internal.Log("red", e.Error())
+					return
+				}
+			}
+
 			// todo NO_COLOR functionality is broken due to colorstring
 			NoColor, e = flags["color"].GetBool()
 			if e != nil {
@@ -572,11 +1449,142 @@ func main() {
 			}
 			internal.OUTLIER_THRESHOLD = outlierThreshold
 
+			metricsString, e := flags["metrics"].GetString()
+			if e != nil {
+				internal.Log("red", "Application error: cannot parse flag values.")
+				return
+			}
+			metrics := map[string]bool{}
+			if metricsString != dummyDefault {
+				metrics, e = internal.ParseMetrics(metricsString)
+				if e != nil {
+					internal.Log("red", e.Error())
+					return
+				}
+			}
+			if metrics[internal.RusageMetrics] && runtime.GOOS == "windows" {
+				internal.Log("yellow", "rusage metrics aren't available on windows, ignoring them.")
+				delete(metrics, internal.RusageMetrics)
+			}
+
+			perfCountersString, e := flags["perf-counters"].GetString()
+			if e != nil {
+				internal.Log("red", "Application error: cannot parse flag values.")
+				return
+			}
+			var perfCounters []string
+			if perfCountersString != dummyDefault {
+				perfCounters, e = parsePerfCounters(perfCountersString)
+				if e != nil {
+					internal.Log("red", e.Error())
+					return
+				}
+			}
+
+			runnerCPUsString, e := flags["runner-cpus"].GetString()
+			if e != nil {
+				internal.Log("red", "Application error: cannot parse flag values.")
+				return
+			}
+			var runnerCPUs []int
+			if runnerCPUsString != dummyDefault {
+				runnerCPUs, e = internal.ParseCPUList(runnerCPUsString)
+				if e == nil {
+					e = checkCPUs(runnerCPUs)
+				}
+				if e == nil {
+					e = pinRunner(runnerCPUs)
+				}
+				if e != nil {
+					internal.Log("red", "unable to restrict atomic to the cpus: "+runnerCPUsString)
+					internal.Log("red", "error: "+e.Error())
+					return
+				}
+			}
+
+			jobs, e := flags["jobs"].GetInt()
+			if e != nil {
+				internal.Log("red", "Application error: cannot parse flag values.")
+				return
+			}
+			if jobs < 1 {
+				internal.Log("red", "the number of jobs must be at least 1.")
+				return
+			}
+
+			orderString, e := flags["order"].GetString()
+			if e != nil {
+				internal.Log("red", "Application error: cannot parse flag values.")
+				return
+			}
+			order, e := parseRunOrder(orderString)
+			if e != nil {
+				internal.Log("red", e.Error())
+				return
+			}
+			seedString, e := flags["seed"].GetString()
+			if e != nil {
+				internal.Log("red", "Application error: cannot parse flag values.")
+				return
+			}
+			seed := time.Now().UnixNano()
+			if seedString != dummyDefault {
+				seed, e = strconv.ParseInt(seedString, 10, 64)
+				if e != nil {
+					internal.Log("red", "invalid seed: "+seedString)
+					return
+				}
+			}
+
+			relativeMetricString, e := flags["relative-metric"].GetString()
+			if e != nil {
+				internal.Log("red", "Application error: cannot parse flag values.")
+				return
+			}
+			relativeMetric, e := internal.ParseMetric(relativeMetricString)
+			if e != nil {
+				internal.Log("red", "invalid relative metric: "+relativeMetricString)
+				return
+			}
+
 			ignoreError, er := flags["ignore-error"].GetBool()
 			if er != nil {
 				internal.Log("red", "Application error: cannot parse flag values.")
 				return
 			}
+			ignoreExitCodesString, er := flags["ignore-exit-codes"].GetString()
+			if er != nil {
+				internal.Log("red", "Application error: cannot parse flag values.")
+				return
+			}
+			var acceptedExitCodes []int
+			if ignoreExitCodesString != dummyDefault {
+				if acceptedExitCodes, er = internal.ParseExitCodes(ignoreExitCodesString); er != nil {
+					internal.Log("red", er.Error())
+					return
+				}
+			}
+			retries, er := flags["retries"].GetInt()
+			if er != nil {
+				internal.Log("red", "Application error: cannot parse flag values.")
+				return
+			}
+			if retries < 0 {
+				internal.Log("red", "the number of retries cannot be negative.")
+				return
+			}
+			maxFailuresString, er := flags["max-failures"].GetString()
+			if er != nil {
+				internal.Log("red", "Application error: cannot parse flag values.")
+				return
+			}
+			var maxFailures internal.FailureBudget
+			if maxFailuresString != dummyDefault {
+				if maxFailures, er = internal.ParseFailureBudget(maxFailuresString); er != nil {
+					internal.Log("red", er.Error())
+					return
+				}
+			}
 
 			useShell, er := flags["shell"].GetBool()
 			if er != nil {
@@ -593,35 +1601,232 @@ func main() {
 				internal.Log("red", "unable to determine the shell to use! supply the name of the shell (if present in $PATH) or the path to the shell using the --shell-path flag.")
 				return
 			}
-			prepareCmdString, err := flags["prepare"].GetString()
+			shellRC, er := flags["shell-rc"].GetBool()
+			if er != nil {
+				internal.Log("red", "Application error: cannot parse flag values.")
+				return
+			}
+			shellModeString, er := flags["shell-mode"].GetString()
+			if er != nil {
+				internal.Log("red", "Application error: cannot parse flag values.")
+				return
+			}
+			shellExec, er := parseShellExecution(shellModeString)
+			if er != nil {
+				internal.Log("red", er.Error())
+				return
+			}
+			shellInit, er := flags["shell-init"].GetString()
+			if er != nil {
+				internal.Log("red", "Application error: cannot parse flag values.")
+				return
+			}
+			if shellInit == dummyDefault {
+				shellInit = ""
+			} else if shellExec != persistentExecution {
+				internal.Log("red", "the --shell-init flag can only be used with --shell-mode persistent.")
+				return
+			}
+			if shellExec == persistentExecution {
+				useShell = true
+				if len(perfCounters) != 0 || metrics[internal.RusageMetrics] {
+					internal.Log("red", "the runs of a persistent shell cannot be measured with --perf-counters or --metrics rusage.")
+					return
+				}
+			}
+			argvMode, er := flags["argv"].GetBool()
+			if er != nil {
+				internal.Log("red", "Application error: cannot parse flag values.")
+				return
+			}
+			if argvMode && useShell {
+				internal.Log("red", "the --argv and -s/--shell flags cannot be used together.")
+				return
+			}
+			var shellProfile internal.ShellProfile
+			var shellArgs []string
+			if useShell {
+				profiles, err := internal.LoadShellProfiles(internal.ShellProfilesPath)
+				if err != nil {
+					internal.Log("red", err.Error())
+					return
+				}
+				var known bool
+				shellProfile, known = internal.LookupShellProfile(profiles, shellPath)
+				if !known {
+					internal.Log("yellow", fmt.Sprintf("Note: there's no profile for the shell `%s`, assuming that it executes commands given with -c. Profiles can be added to %s.", shellPath, internal.ShellProfilesPath))
+				}
+				shellArgs = shellProfile.Args(shellPath, shellRC)
+				if shellExec == persistentExecution && !shellProfile.POSIX {
+					internal.Log("red", fmt.Sprintf("the shell `%s` cannot be kept persistent, only POSIX shells can (see the posix field of the shell profiles).", shellPath))
+					return
+				}
+			}
+			givenCommands := strings.Split(args["commands"].Value, commando.VariadicSeparator)
+			commandNames := internal.RepeatedFlagValues(os.Args[1:], "command-name", "n")
+			if len(commandNames) > len(givenCommands) {
+				internal.Log("red", fmt.Sprintf("%d command names were given for %d commands.", len(commandNames), len(givenCommands)))
+				return
+			}
+			prepareCmdStrings, err := getPositionalFlag(len(givenCommands), "prepare", "p")
+			if err != nil {
+				internal.Log("red", err.Error())
+				return
+			}
+			cleanupCmdStrings, err := getPositionalFlag(len(givenCommands), "cleanup", "c")
+			if err != nil {
+				internal.Log("red", err.Error())
+				return
+			}
+			setupCmdStrings, err := getPositionalFlag(len(givenCommands), "setup")
+			if err != nil {
+				internal.Log("red", err.Error())
+				return
+			}
+			concludeCmdStrings, err := getPositionalFlag(len(givenCommands), "conclude")
 			if err != nil {
-				internal.Log("red", "unable to parse the given command: "+prepareCmdString)
-				internal.Log("red", "error: "+err.Error())
+				internal.Log("red", err.Error())
 				return
 			}
-			executePrepareCmd := prepareCmdString != dummyDefault
-			var prepareCmd []string
-			prepareCmd, err = buildCommand(prepareCmdString, useShell, shellPath)
+			cpusStrings, err := getPositionalFlag(len(givenCommands), "cpus")
 			if err != nil {
-				internal.Log("red", "unable to parse the given command: "+prepareCmdString)
-				internal.Log("red", "error: "+err.Error())
+				internal.Log("red", err.Error())
 				return
 			}
+			cwdStrings, err := getPositionalFlag(len(givenCommands), "cwd")
+			if err != nil {
+				internal.Log("red", err.Error())
+				return
+			}
+			isolateStrings, err := getPositionalFlag(len(givenCommands), "isolate")
+			if err != nil {
+				internal.Log("red", err.Error())
+				return
+			}
+			var globalEnv []string
+			for _, variable := range internal.RepeatedFlagValues(os.Args[1:], "env") {
+				if _, err := internal.ParseEnvVar(variable); err != nil {
+					internal.Log("red", err.Error())
+					return
+				}
+				globalEnv = append(globalEnv, variable)
+			}
+			commandEnvStrings, err := getPositionalFlag(len(givenCommands), "command-env")
+			if err != nil {
+				internal.Log("red", err.Error())
+				return
+			}
+			cleanEnv, err := flags["clean-env"].GetBool()
+			if err != nil {
+				internal.Log("red", "Application error: cannot parse flag values.")
+				return
+			}
+			var baseTargets []benchmarkTarget
+			for i, command := range givenCommands {
+				commandEnv, err := internal.ParseEnvList(commandEnvStrings[i])
+				if err != nil {
+					internal.Log("red", "unable to parse the environment of the command: "+commandEnvStrings[i])
+					internal.Log("red", "error: "+err.Error())
+					return
+				}
+				target := benchmarkTarget{
+					template: command,
+					command:  command,
+					prepare:  prepareCmdStrings[i],
+					cleanup:  cleanupCmdStrings[i],
+					setup:    setupCmdStrings[i],
+					conclude: concludeCmdStrings[i],
+					cpus:     cpusStrings[i],
+					env:      append(slices.Clip(globalEnv), commandEnv...),
+					cwd:      cwdStrings[i],
+					isolate:  isolateStrings[i],
+				}
+				if target.cwd != "" && target.isolate != "" {
+					internal.Log("red", "the --cwd and --isolate flags cannot be used together for a command, the isolated runs are executed in the copy of the fixture.")
+					return
+				}
+				if i < len(commandNames) {
+					target.name = commandNames[i]
+					target.template = commandNames[i]
+				}
+				baseTargets = append(baseTargets, target)
+			}
 
-			cleanupCmdString, err := flags["cleanup"].GetString()
+			parameterScanString, err := flags["parameter-scan"].GetString()
 			if err != nil {
-				internal.Log("red", "unable to parse the given command: "+cleanupCmdString)
-				internal.Log("red", "error: "+err.Error())
+				internal.Log("red", "Application error: cannot parse flag values.")
 				return
 			}
-			executeCleanupCmd := cleanupCmdString != dummyDefault
-			var cleanupCmd []string
-			cleanupCmd, err = buildCommand(cleanupCmdString, useShell, shellPath)
+			var parameters []internal.Parameter
+			var parameterCombinations []map[string]string
+			if parameterScanString != dummyDefault {
+				parameters, err = internal.ParseParameterScan(parameterScanString)
+				if err != nil {
+					internal.Log("red", "unable to parse the parameter scan: "+parameterScanString)
+					internal.Log("red", "error: "+err.Error())
+					return
+				}
+				parameterCombinations, err = internal.ExpandParameters(parameters)
+				if err != nil {
+					internal.Log("red", "unable to expand the parameter scan: "+parameterScanString)
+					internal.Log("red", "error: "+err.Error())
+					return
+				}
+			}
+
+			var includeRules, excludeRules []map[string]string
+			for _, ruleFlag := range []string{"parameter-include", "parameter-exclude"} {
+				rulesString, err := flags[ruleFlag].GetString()
+				if err != nil {
+					internal.Log("red", "Application error: cannot parse flag values.")
+					return
+				}
+				if rulesString == dummyDefault {
+					continue
+				}
+				if parameters == nil {
+					internal.Log("red", "the --"+ruleFlag+" flag can only be used along with the --parameter-scan flag.")
+					return
+				}
+				rules, err := internal.ParseParameterRules(rulesString, parameters)
+				if err != nil {
+					internal.Log("red", "unable to parse the rules: "+rulesString)
+					internal.Log("red", "error: "+err.Error())
+					return
+				}
+				if ruleFlag == "parameter-include" {
+					includeRules = rules
+				} else {
+					excludeRules = rules
+				}
+			}
+			if includeRules != nil || excludeRules != nil {
+				parameterCombinations = internal.FilterParameterCombinations(parameterCombinations, includeRules, excludeRules)
+				if len(parameterCombinations) == 0 {
+					internal.Log("red", "no parameter combinations are left to benchmark after applying the include and exclude rules.")
+					return
+				}
+			}
+
+			pivotString, err := flags["pivot"].GetString()
 			if err != nil {
-				internal.Log("red", "unable to parse the given command: "+cleanupCmdString)
-				internal.Log("red", "error: "+err.Error())
+				internal.Log("red", "Application error: cannot parse flag values.")
 				return
 			}
+			var pivot *internal.PivotTable
+			if pivotString != dummyDefault {
+				if parameters == nil {
+					internal.Log("red", "the --pivot flag can only be used along with the --parameter-scan flag.")
+					return
+				}
+				rowAxis, columnAxis, err := internal.ParsePivotAxes(pivotString, parameters)
+				if err != nil {
+					internal.Log("red", "unable to parse the pivot axes: "+pivotString)
+					internal.Log("red", "error: "+err.Error())
+					return
+				}
+				pivot = internal.NewPivotTable(parameters, rowAxis, columnAxis)
+			}
 
 			timeoutString, err := flags["timeout"].GetString()
 			if err != nil {
@@ -634,6 +1839,16 @@ func main() {
 				internal.Log("red", "error: ")
 				return
 			}
+			onTimeoutString, err := flags["on-timeout"].GetString()
+			if err != nil {
+				internal.Log("red", "Application error: cannot parse flag values.")
+				return
+			}
+			onTimeout, err := parseTimeoutAction(onTimeoutString)
+			if err != nil {
+				internal.Log("red", err.Error())
+				return
+			}
 
 			timeUnitString, err := flags["time-unit"].GetString()
 			if err != nil {
@@ -676,31 +1891,77 @@ func main() {
 				return
 			}
 
+			inputPath, err := flags["input"].GetString()
+			if err != nil {
+				internal.Log("red", "Application error: cannot parse flag values.")
+				return
+			}
+			inputBytesString, err := flags["input-bytes"].GetString()
+			if err != nil {
+				internal.Log("red", "Application error: cannot parse flag values.")
+				return
+			}
+			inputFill, err := flags["input-fill"].GetString()
+			if err != nil {
+				internal.Log("red", "Application error: cannot parse flag values.")
+				return
+			}
+			switch {
+			case inputPath != dummyDefault && inputBytesString != dummyDefault:
+				internal.Log("red", "the --input and --input-bytes flags cannot be used together.")
+				return
+			case inputPath != dummyDefault:
+				// the commands may be executed in other directories
+				if inputPath, err = filepath.Abs(inputPath); err == nil {
+					_, err = os.Stat(inputPath)
+				}
+				if err != nil {
+					internal.Log("red", "unable to read the input file: "+err.Error())
+					return
+				}
+			case inputBytesString != dummyDefault:
+				inputBytes, err := internal.ParseByteSize(inputBytesString)
+				if err != nil {
+					internal.Log("red", "invalid number of input bytes: "+inputBytesString)
+					return
+				}
+				inputPath, err = generateInput(inputBytes, inputFill)
+				if err != nil {
+					internal.Log("red", "unable to generate the input: "+err.Error())
+					return
+				}
+				defer os.Remove(inputPath)
+			default:
+				inputPath = ""
+			}
+
+			// from now on an interrupt stops the benchmark instead of killing atomic
+			interrupts.listen()
+
+			// a persistent shell only measures the commands themselves, there's no spawn time to subtract
 			var shellCalibration = emptyRunResult()
-			if useShell {
-				shellEmptyCommand, err := buildCommand("''", true, shellPath)
+			if useShell && shellExec == spawnExecution {
+				shellEmptyCommand, err := buildCommand(shellProfile.CalibrationCommand, shellArgs)
 				if err != nil {
 					internal.Log("red", "unable to calibrate shell: make sure you can run "+shellPath)
 					internal.Log("red", "error: "+err.Error())
 					return
 				}
 				calibrationOpts := BenchmarkOptions{
-					command:           shellEmptyCommand,
-					runs:              -1,
-					verbose:           false,
-					ignoreError:       true,
-					executePrepareCmd: false,
-					prepareCmd:        []string{},
-					executeCleanupCmd: false,
-					cleanupCmd:        []string{},
-					mode:              shellMode,
-					timeout:           LargestDuration,
-					shellCalibration:  emptyRunResult(),
-				}
-				runs, failed := Benchmark(calibrationOpts)
-				if failed {
+					runs:             -1,
+					verbose:          false,
+					ignoreError:      true,
+					mode:             shellMode,
+					timeout:          LargestDuration,
+					shellCalibration: emptyRunResult(),
+					order:            sequentialOrder,
+				}
+				calibration := &benchmarkCommand{command: shellEmptyCommand, target: benchmarkTarget{command: shellProfile.CalibrationCommand}}
+				Benchmark([]*benchmarkCommand{calibration}, calibrationOpts)
+				if calibration.failed {
 					return
 				}
+				runs := calibration.runsData
 				shellElapsedAvg := internal.CalculateAverage(internal.MapFunc[[]*RunResult, []float64](func(r *RunResult) float64 { return float64(r.elapsed.Microseconds()) }, runs))
 				shellUserAvg := internal.CalculateAverage(internal.MapFunc[[]*RunResult, []float64](func(r *RunResult) float64 { return float64(r.user.Microseconds()) }, runs))
 				shellSystemAvg := internal.CalculateAverage(internal.MapFunc[[]*RunResult, []float64](func(r *RunResult) float64 { return float64(r.system.Microseconds()) }, runs))
@@ -713,113 +1974,355 @@ func main() {
 					system:  shellSystemAvgDuration,
 				}
 			}
-			// fmt.Println(shellCalibration)
 
 			var speedResults []*internal.SpeedResult
-			// * benchmark each command given
-			givenCommands := strings.Split(args["commands"].Value, commando.VariadicSeparator)
-			nCommands := len(givenCommands)
-			for index, commandString := range givenCommands {
-				if _, err := colorstring.Printf("[bold][magenta]Benchmark %d: [cyan]%s", index+1, commandString); err != nil {
-					panic(err)
+			targets := expandTargets(baseTargets, parameterCombinations)
+			nCommands := len(targets)
+
+			// * build every command given
+			var commands []*benchmarkCommand
+			for index, target := range targets {
+				bc := &benchmarkCommand{index: index, target: target}
+				if cleanEnv {
+					bc.env = append(internal.CleanEnv(), target.env...)
+				} else if len(target.env) != 0 {
+					bc.env = append(os.Environ(), target.env...)
 				}
-				// ! don't remove this println: for some weird reason the above colorstring.Printf
-				// ! doesnt' work without this
-				fmt.Println()
-
-				command, err := buildCommand(commandString, useShell, shellPath)
-				if err != nil {
-					internal.Log("red", "unable to parse the given command: "+commandString)
+				// prints the heading of a command which cannot be benchmarked, along with the reason
+				invalid := func(what, value string, err error) {
+					if _, err := colorstring.Printf("[bold][magenta]Benchmark %d: [cyan]%s[white]%s", index+1, bc.heading(), bc.parametersHeading()); err != nil {
+						panic(err)
+					}
+					fmt.Println()
+					internal.Log("red", what+value)
 					internal.Log("red", "error: "+err.Error())
-					continue
+					fmt.Println()
 				}
 
-				warmupOpts := BenchmarkOptions{
-					command:           command,
-					runs:              warmupRuns,
-					verbose:           verbose,
-					ignoreError:       ignoreError,
-					prepareCmd:        prepareCmd,
-					executePrepareCmd: executePrepareCmd,
-					executeCleanupCmd: executeCleanupCmd,
-					cleanupCmd:        cleanupCmd,
-					shellCalibration:  shellCalibration,
-					mode:              warmupMode,
-					timeout:           timeout,
+				commandStrings := []struct {
+					value string
+					cmd   *[]string
+				}{
+					{target.command, &bc.command},
+					{target.prepare, &bc.prepareCmd},
+					{target.cleanup, &bc.cleanupCmd},
+					{target.setup, &bc.setupCmd},
+					{target.conclude, &bc.concludeCmd},
 				}
-
-				// no need for runs in warmups
-				_, shouldSkip := Benchmark(warmupOpts)
-				if shouldSkip {
+				valid := true
+				for _, cs := range commandStrings {
+					if cs.value == "" {
+						continue
+					}
+					if argvMode && cs.cmd == &bc.command {
+						*cs.cmd, err = parseArgv(cs.value)
+					} else {
+						*cs.cmd, err = buildCommand(cs.value, shellArgs)
+					}
+					if err != nil {
+						invalid("unable to parse the given command: ", cs.value, err)
+						valid = false
+						break
+					}
+				}
+				if !valid {
 					continue
 				}
 
-				benchmarkOpts := warmupOpts
-				benchmarkOpts.runs = runs
-				benchmarkOpts.mode = mainMode
+				if target.cwd != "" {
+					// absolute, since a persistent shell changes to it before every run
+					bc.dir, err = filepath.Abs(target.cwd)
+					if info, e := os.Stat(bc.dir); err == nil && (e != nil || !info.IsDir()) {
+						err = e
+						if err == nil {
+							err = errors.New("not a directory")
+						}
+					}
+					if err != nil {
+						invalid("unable to use the working directory: ", target.cwd, err)
+						continue
+					}
+				}
+				if target.isolate != "" {
+					err = internal.CheckFixture(target.isolate)
+					if err == nil {
+						bc.fixture, err = filepath.Abs(target.isolate)
+					}
+					if err != nil {
+						invalid("unable to isolate the runs in the fixture: ", target.isolate, err)
+						continue
+					}
+				}
 
-				runsData, shouldSkip := Benchmark(benchmarkOpts)
-				if shouldSkip {
-					continue
+				if target.cpus != "" {
+					bc.cpus, err = internal.ParseCPUList(target.cpus)
+					if err == nil {
+						err = checkCPUs(bc.cpus)
+					}
+					if err != nil {
+						invalid("unable to restrict the command to the cpus: ", target.cpus, err)
+						continue
+					}
+					if slices.ContainsFunc(bc.cpus, func(cpu int) bool { return slices.Contains(runnerCPUs, cpu) }) {
+						internal.Log("yellow", "Warning: the command shares cpus with atomic itself (--runner-cpus), which may disturb the measurements.")
+					}
 				}
-				elapsedTimes := internal.MapFunc[[]*RunResult, []float64](func(rr *RunResult) float64 { return float64(rr.elapsed.Microseconds()) }, runsData)
-				userTimes := internal.MapFunc[[]*RunResult, []float64](func(rr *RunResult) float64 { return float64(rr.user.Microseconds()) }, runsData)
-				systemTimes := internal.MapFunc[[]*RunResult, []float64](func(rr *RunResult) float64 { return float64(rr.system.Microseconds()) }, runsData)
-
-				// * intialising the template struct
-				avgElapsed := internal.CalculateAverage(elapsedTimes)
-				avgUser := internal.CalculateAverage(userTimes)
-				avgSystem := internal.CalculateAverage(systemTimes)
-				if avgElapsed < 0 {
-					internal.Log("red", "shell calibration is yielding inaccurate results")
-					internal.Log("yellow", "Try executing the command without the -s/--shell flag.")
-					continue
+				commands = append(commands, bc)
+			}
+
+			// commands are benchmarked one after another in the sequential order, otherwise all together
+			groups := [][]*benchmarkCommand{commands}
+			if order == sequentialOrder {
+				groups = internal.MapFunc[[]*benchmarkCommand, [][]*benchmarkCommand](func(bc *benchmarkCommand) []*benchmarkCommand { return []*benchmarkCommand{bc} }, commands)
+			} else if len(commands) > 1 {
+				orderDescription := string(order) + " order"
+				if order == shuffledOrder {
+					orderDescription += fmt.Sprintf(" (seed %d)", seed)
 				}
-				stddev := internal.CalculateStandardDeviation(elapsedTimes, avgElapsed)
-				max_ := slices.Max(elapsedTimes)
-				min_ := slices.Min(elapsedTimes)
-				speedResult := &internal.SpeedResult{
-					Command:           commandString,
-					AverageElapsed:    avgElapsed,
-					AverageUser:       avgUser,
-					AverageSystem:     avgSystem,
-					StandardDeviation: stddev,
-					Max:               max_,
-					Min:               min_,
-					Times:             elapsedTimes,
-				}
-				printableResult := internal.NewPrintableResult().FromSpeedResult(*speedResult)
-				speedResults = append(speedResults, speedResult)
-				fmt.Print(printableResult.String())
-
-				outliersDetected := internal.TestOutliers(elapsedTimes)
-				if outliersDetected {
-					internal.Log("yellow", "\nWarning: Statistical outliers were detected. Consider re-running this benchmark on a quiet system, devoid of any interferences from other programs.")
-					if warmupRuns == 0 {
-						internal.Log("yellow", "It might help to use the --warmup flag.")
+				colorstring.Printf("[bold][magenta]Benchmarking %d commands in %s", len(commands), orderDescription)
+				fmt.Println()
+			}
+
+			warmupOpts := BenchmarkOptions{
+				runs:              warmupRuns,
+				verbose:           verbose,
+				output:            output,
+				ignoreError:       ignoreError,
+				acceptedExitCodes: acceptedExitCodes,
+				retries:           retries,
+				maxFailures:       maxFailures,
+				shellCalibration:  shellCalibration,
+				mode:              warmupMode,
+				timeout:           timeout,
+				onTimeout:         onTimeout,
+				input:             inputPath,
+				order:             order,
+				rng:               rand.New(rand.NewSource(seed)),
+				jobs:              jobs,
+			}
+			if shellExec == persistentExecution {
+				warmupOpts.persistentShell = shellProfile.PersistentArgs(shellPath, shellRC)
+				warmupOpts.shellInit = shellInit
+			}
+			benchmarkOpts := warmupOpts
+			benchmarkOpts.runs = runs
+			benchmarkOpts.mode = mainMode
+			benchmarkOpts.duration = duration
+			benchmarkOpts.precision = precision
+			benchmarkOpts.precisionStat = precisionStat
+			// the warmup runs needn't be measured
+			benchmarkOpts.perfCounters = perfCounters
+
+			for g, group := range groups {
+				if interrupts.interrupted() {
+					remaining := 0
+					for _, rest := range groups[g:] {
+						remaining += len(rest)
+					}
+					if remaining == 1 {
+						internal.Log("yellow", "The benchmark was interrupted, the remaining command wasn't benchmarked.\n")
 					} else {
-						internal.Log("yellow", "Since you're already using the --warmup flag, you can consider increasing the warmup count.")
+						internal.Log("yellow", fmt.Sprintf("The benchmark was interrupted, the remaining %d commands weren't benchmarked.\n", remaining))
+					}
+					break
+				}
+				if order == sequentialOrder {
+					if _, err := colorstring.Printf("[bold][magenta]Benchmark %d: [cyan]%s[white]%s", group[0].index+1, group[0].heading(), group[0].parametersHeading()); err != nil {
+						panic(err)
 					}
+					// ! don't remove this println: for some weird reason the above colorstring.Printf
+					// ! doesnt' work without this
+					fmt.Println()
 				}
 
-				// min is in microseconds
-				if min_ < float64((5 * time.Millisecond).Microseconds()) {
-					internal.Log("yellow", "\nWarning: The command took less than 5ms to execute, the results might be inaccurate.")
-					if useShell {
-						internal.Log("yellow", "Try running the command without the -s/--shell flag.")
+				var setUp []*benchmarkCommand
+				for _, bc := range group {
+					if interrupts.interrupted() {
+						bc.interrupted = true
+						continue
+					}
+					if bc.setupCmd != nil && runStage(bc.setupCmd, setupStage, verbose, bc.env, bc.dir, interrupts.stopped) {
+						bc.interrupted = interrupts.interrupted()
+						bc.failed = !bc.interrupted
+						continue
 					}
+					setUp = append(setUp, bc)
 				}
 
-				if index != (nCommands-1) || nCommands > 1 {
-					// print new line b/w each benchmark
-					// and at the end one too if relative summary
-					// has to be printed
-					fmt.Println()
+				// no need for runs in warmups
+				Benchmark(setUp, warmupOpts)
+				Benchmark(setUp, benchmarkOpts)
+				for _, bc := range setUp {
+					bc.closeShells()
+				}
+
+				// conclude even if the benchmark failed, since it may be tearing down what the setup started
+				for _, bc := range setUp {
+					// an interrupt stops the conclude command in flight, the ones after it still tear down
+					stop := interrupts.stopped
+					if interrupts.interrupted() {
+						stop = nil
+					}
+					if bc.concludeCmd != nil && runStage(bc.concludeCmd, concludeStage, verbose, bc.env, bc.dir, stop) && !interrupts.interrupted() {
+						bc.failed = true
+					}
 				}
 
+				for _, bc := range group {
+					if order != sequentialOrder {
+						colorstring.Printf("[bold][magenta]Benchmark %d: [cyan]%s[white]%s", bc.index+1, bc.heading(), bc.parametersHeading())
+						fmt.Println()
+						if bc.failed {
+							internal.Log("red", "The benchmark failed, see the error above.\n")
+						}
+					}
+					if bc.failed {
+						continue
+					}
+					if len(bc.runsData) == 0 && len(bc.discarded) == 0 {
+						if bc.interrupted {
+							internal.Log("yellow", "No run was completed before the interrupt.\n")
+						} else {
+							internal.Log("yellow", "No run was performed.\n")
+						}
+						continue
+					}
+					if len(bc.runsData) == 0 {
+						internal.Log("red", fmt.Sprintf("All the %d runs failed and were discarded, the last one with %s.\n", len(bc.discarded), bc.discarded[len(bc.discarded)-1]))
+						continue
+					}
+					target := bc.target
+					runsData := bc.runsData
+					elapsedTimes := internal.MapFunc[[]*RunResult, []float64](func(rr *RunResult) float64 { return float64(rr.elapsed.Microseconds()) }, runsData)
+					userTimes := internal.MapFunc[[]*RunResult, []float64](func(rr *RunResult) float64 { return float64(rr.user.Microseconds()) }, runsData)
+					systemTimes := internal.MapFunc[[]*RunResult, []float64](func(rr *RunResult) float64 { return float64(rr.system.Microseconds()) }, runsData)
+
+					// * intialising the template struct
+					avgElapsed := internal.CalculateAverage(elapsedTimes)
+					avgUser := internal.CalculateAverage(userTimes)
+					avgSystem := internal.CalculateAverage(systemTimes)
+					if avgElapsed < 0 {
+						internal.Log("red", "shell calibration is yielding inaccurate results")
+						internal.Log("yellow", "Try executing the command without the -s/--shell flag.")
+						continue
+					}
+					stddev := internal.CalculateStandardDeviation(elapsedTimes, avgElapsed)
+					max_ := slices.Max(elapsedTimes)
+					min_ := slices.Min(elapsedTimes)
+					outputBytes := internal.MapFunc[[]*RunResult, []float64](func(rr *RunResult) float64 { return float64(rr.outputBytes) }, runsData)
+					memoryUsages := internal.MapFunc[[]*RunResult, []float64](func(rr *RunResult) float64 { return float64(rr.maxRSS) }, runsData)
+					avgMemory := internal.CalculateAverage(memoryUsages)
+					exitCodes := internal.MapFunc[[]*RunResult, []int](func(rr *RunResult) int { return rr.exitCode }, runsData)
+					signals := internal.MapFunc[[]*RunResult, []string](func(rr *RunResult) string { return rr.signal }, runsData)
+					if !slices.ContainsFunc(signals, func(signal string) bool { return signal != "" }) {
+						signals = nil
+					}
+					timedOut := internal.MapFunc[[]*RunResult, []bool](func(rr *RunResult) bool { return rr.timedOut }, runsData)
+					if !slices.Contains(timedOut, true) {
+						timedOut = nil
+					}
+					speedResult := &internal.SpeedResult{
+						Command:           target.command,
+						Name:              target.name,
+						Prepare:           target.prepare,
+						Cleanup:           target.cleanup,
+						Setup:             target.setup,
+						Conclude:          target.conclude,
+						CPUs:              bc.cpus,
+						OutputMode:        string(output),
+						OutputBytes:       internal.CalculateAverage(outputBytes),
+						AverageMemory:     avgMemory,
+						MemoryStddev:      internal.CalculateStandardDeviation(memoryUsages, avgMemory),
+						MinMemory:         slices.Min(memoryUsages),
+						MaxMemory:         slices.Max(memoryUsages),
+						MemoryUsages:      memoryUsages,
+						ExitCodes:         exitCodes,
+						AcceptedExitCodes: acceptedExitCodes,
+						Signals:           signals,
+						TimedOut:          timedOut,
+						Retries:           bc.retries,
+						Discarded:         bc.discarded,
+						AverageElapsed:    avgElapsed,
+						AverageUser:       avgUser,
+						AverageSystem:     avgSystem,
+						StandardDeviation: stddev,
+						Max:               max_,
+						Min:               min_,
+						Times:             elapsedTimes,
+						Parameters:        target.parameters,
+						Env:               internal.EnvMap(target.env),
+						CleanEnv:          cleanEnv,
+						Cwd:               target.cwd,
+						Isolate:           target.isolate,
+						Partial:           bc.interrupted,
+						PersistentShell:   shellExec == persistentExecution,
+					}
+					if cleanEnv {
+						// the whole environment is known then
+						speedResult.Env = internal.EnvMap(bc.env)
+					}
+					if jobs > 1 {
+						speedResult.Jobs = jobs
+						speedResult.Throughput = bc.throughput()
+					}
+					if precision > 0 {
+						// left at zero when it can't be estimated, e.g. for a zero run time
+						if achieved := internal.RelativePrecision(elapsedTimes, precisionStat); !math.IsInf(achieved, 0) {
+							speedResult.Precision = achieved
+						}
+						speedResult.PrecisionTarget = precision
+						speedResult.PrecisionStatistic = string(precisionStat)
+					}
+					if metrics[internal.RusageMetrics] {
+						speedResult.ResourceUsages = internal.MapFunc[[]*RunResult, []internal.ResourceUsage](func(rr *RunResult) internal.ResourceUsage { return rr.rusage }, runsData)
+						speedResult.ResourceUsage = internal.AverageResourceUsage(speedResult.ResourceUsages)
+					}
+					if len(perfCounters) != 0 {
+						perfValues := internal.MapFunc[[]*RunResult, []map[string]float64](func(rr *RunResult) map[string]float64 { return rr.perfCounters }, runsData)
+						speedResult.PerfCounters = internal.AggregatePerfCounters(perfCounters, perfValues)
+					}
+					printableResult := internal.NewPrintableResult().FromSpeedResult(*speedResult, time.Microsecond)
+					speedResults = append(speedResults, speedResult)
+					if pivot != nil {
+						pivot.Add(target.template, speedResult)
+					}
+					fmt.Print(printableResult.String())
+
+					outliersDetected := internal.TestOutliers(elapsedTimes)
+					if outliersDetected {
+						internal.Log("yellow", "\nWarning: Statistical outliers were detected. Consider re-running this benchmark on a quiet system, devoid of any interferences from other programs.")
+						if warmupRuns == 0 {
+							internal.Log("yellow", "It might help to use the --warmup flag.")
+						} else {
+							internal.Log("yellow", "Since you're already using the --warmup flag, you can consider increasing the warmup count.")
+						}
+					}
+
+					if precision > 0 && !speedResult.PrecisionMet() && !speedResult.Partial {
+						internal.Log("yellow", fmt.Sprintf("\nWarning: The precision target was not met within %d runs. Consider raising the --max flag.", len(elapsedTimes)))
+					}
+
+					// min is in microseconds
+					if min_ < float64((5 * time.Millisecond).Microseconds()) {
+						internal.Log("yellow", "\nWarning: The command took less than 5ms to execute, the results might be inaccurate.")
+						if useShell && shellExec == spawnExecution {
+							internal.Log("yellow", "Try running the command without the -s/--shell flag, or with --shell-mode persistent.")
+						}
+					}
+
+					if bc.index != (nCommands-1) || nCommands > 1 {
+						// print new line b/w each benchmark
+						// and at the end one too if relative summary
+						// has to be printed
+						fmt.Println()
+					}
+				}
 			}
 
-			internal.RelativeSummary(speedResults)
+			internal.RelativeSummary(speedResults, relativeMetric)
+			if pivot != nil {
+				internal.PrintPivotTable(pivot)
+			}
 
 			// modify speedResults to convert values from microseconds to timeUnit
 			// if and only if either export or plotting needs to be done
@@ -829,7 +2332,17 @@ func main() {
 
 			if exportFormatString != "none" {
 				fmt.Println()
-				internal.Export(exportFormats, filename, speedResults, timeUnit)
+				metadata := map[string]any{}
+				if runnerCPUs != nil {
+					metadata["runner_cpus"] = runnerCPUs
+				}
+				metadata["order"] = order
+				if order == shuffledOrder {
+					metadata["seed"] = seed
+				}
+				if err := internal.Export(exportFormats, filename, speedResults, pivot, timeUnit, metadata); err != nil {
+					internal.Log("red", err.Error())
+				}
 			}
 
 			if plotString != "none" {
@@ -839,4 +2352,9 @@ func main() {
 		})
 
 	commando.Parse(nil)
+	fmt.Println(update)
+	if interrupts.interrupted() {
+		// the conventional exit code of a program interrupted by SIGINT
+		os.Exit(130)
+	}
 }
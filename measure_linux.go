@@ -0,0 +1,270 @@
+//go:build linux
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// hidden first argument which makes atomic act as the helper measuring a command
+const measureHelperArg = "__atomic_measure"
+
+// the shell script which hands the command over to the helper: the command is started in the background,
+// waits for a line over fd 3 before executing and gets back the stdin the shell gives the background commands
+// the null device as, while the shell tells its pid over fd 4 and exits. "$@" is the command.
+const measureHelperScript = `exec 5<&0
+{ read -r _ <&3 || exit 127; exec "$@" 3<&- 4>&- 5<&-; } <&5 &
+echo $! >&4`
+
+// measurement starts a command and tells how it exited. A child shares the memory of its parent until it executes
+// the command, and Linux counts that memory towards the peak memory usage of the command, so every command started
+// by atomic itself would be reported to use at least as much memory as atomic does. Instead, the command is started
+// from a lean shell through a helper (atomic itself), which the command is reparented to once the shell exits, so
+// that the helper can wait for it and report its exit status and resource usage, peak memory usage included.
+type measurement struct {
+	cmd *exec.Cmd
+	// the helper tells the pid and the process group of the command over this pipe, and then how it exited...
+	report *os.File
+	// ...while the command waits for a line over this one before executing
+	release *os.File
+	// the other ends of the pipes, which belong to the helper once it's started
+	helperEnds []*os.File
+	// the process group of the command
+	group *os.Process
+	pid   int
+	// how the command exited as reported by the helper, nil if the helper didn't report it
+	exit *helperState
+	// when the command exited
+	exited time.Time
+}
+
+// prepareMeasurement rewrites the command to be started through the helper if `exact` is set, otherwise
+// the command is started as it is and its peak memory usage includes the memory atomic uses.
+func prepareMeasurement(cmd *exec.Cmd, exact bool) (*measurement, error) {
+	if !exact {
+		return &measurement{cmd: cmd}, nil
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	releaseRead, releaseWrite, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	reportRead, reportWrite, err := os.Pipe()
+	if err != nil {
+		releaseRead.Close()
+		releaseWrite.Close()
+		return nil, err
+	}
+	// available to the helper as fd 3 and 4
+	cmd.ExtraFiles = []*os.File{releaseRead, reportWrite}
+	cmd.Args = append([]string{self, measureHelperArg, cmd.Path}, cmd.Args[1:]...)
+	cmd.Path = self
+	return &measurement{
+		cmd:        cmd,
+		report:     reportRead,
+		release:    releaseWrite,
+		helperEnds: []*os.File{releaseRead, reportWrite},
+	}, nil
+}
+
+// started closes the ends of the pipes which now belong to the started helper, so that
+// reading the report fails instead of blocking if the helper dies.
+func (m *measurement) started() {
+	for _, f := range m.helperEnds {
+		f.Close()
+	}
+	m.helperEnds = nil
+}
+
+// ready waits for the helper to hand over the command, which hasn't executed yet.
+func (m *measurement) ready() error {
+	if m.report == nil {
+		m.group = m.cmd.Process
+		m.pid = m.cmd.Process.Pid
+		return nil
+	}
+	var ids [2]int64
+	if err := binary.Read(m.report, binary.LittleEndian, &ids); err != nil {
+		return fmt.Errorf("the measuring helper didn't start the command: %w", err)
+	}
+	m.pid = int(ids[0])
+	// always succeeds on unix, even once the shell which led the group has exited
+	m.group, _ = os.FindProcess(int(ids[1]))
+	return nil
+}
+
+// held tells whether the command is started through the helper, which holds it until [measurement.start].
+func (m *measurement) held() bool {
+	return m.release != nil
+}
+
+// start lets the command execute.
+func (m *measurement) start() error {
+	if m.release == nil {
+		return nil
+	}
+	_, err := m.release.Write([]byte{'\n'})
+	return err
+}
+
+// wait waits for the command to exit, returning an error like [exec.Cmd.Wait] does if it didn't exit successfully.
+func (m *measurement) wait() error {
+	if m.report == nil {
+		err := m.cmd.Wait()
+		m.exited = time.Now()
+		return err
+	}
+	var status uint32
+	var rusage syscall.Rusage
+	reportErr := binary.Read(m.report, binary.LittleEndian, &status)
+	if reportErr == nil {
+		reportErr = binary.Read(m.report, binary.LittleEndian, &rusage)
+	}
+	m.exited = time.Now()
+	// the helper exits right after the report, it's waited for its output to be drained
+	err := m.cmd.Wait()
+	if reportErr != nil {
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("the measuring helper didn't report how the command exited: %w", reportErr)
+	}
+	m.exit = &helperState{status: syscall.WaitStatus(status), rusage: rusage}
+	if !m.exit.status.Exited() || m.exit.status.ExitStatus() != 0 {
+		return errors.New(m.exit.String())
+	}
+	return nil
+}
+
+// state tells how the command exited, or how the helper did if it didn't report it.
+func (m *measurement) state() processState {
+	if m.exit != nil {
+		return m.exit
+	}
+	return m.cmd.ProcessState
+}
+
+// close releases the pipes, which makes the command exit without executing if it hasn't been released yet.
+func (m *measurement) close() {
+	m.started()
+	if m.report != nil {
+		m.report.Close()
+		m.release.Close()
+	}
+	if m.group != nil && m.group != m.cmd.Process {
+		m.group.Release()
+	}
+}
+
+// helperState is the [processState] of a command, as reported by the helper.
+type helperState struct {
+	status syscall.WaitStatus
+	rusage syscall.Rusage
+}
+
+func (hs *helperState) ExitCode() int {
+	if !hs.status.Exited() {
+		return -1
+	}
+	return hs.status.ExitStatus()
+}
+
+func (hs *helperState) UserTime() time.Duration {
+	return time.Duration(hs.rusage.Utime.Nano())
+}
+
+func (hs *helperState) SystemTime() time.Duration {
+	return time.Duration(hs.rusage.Stime.Nano())
+}
+
+func (hs *helperState) Sys() any {
+	return hs.status
+}
+
+func (hs *helperState) SysUsage() any {
+	return &hs.rusage
+}
+
+// String describes the exit status like [os.ProcessState.String] does.
+func (hs *helperState) String() string {
+	switch {
+	case hs.status.Exited():
+		return "exit status " + strconv.Itoa(hs.status.ExitStatus())
+	case hs.status.Signaled() && hs.status.CoreDump():
+		return "signal: " + hs.status.Signal().String() + " (core dumped)"
+	case hs.status.Signaled():
+		return "signal: " + hs.status.Signal().String()
+	default:
+		return "unknown exit status " + strconv.Itoa(int(hs.status))
+	}
+}
+
+// runMeasureHelper is the main function of atomic when it's started as the helper measuring a command.
+// args are the path of the command followed by its arguments.
+func runMeasureHelper(args []string) {
+	release := os.NewFile(3, "release")
+	report := os.NewFile(4, "report")
+	// only the release pipe is handed over to the command
+	syscall.CloseOnExec(4)
+	fail := func(err error) {
+		fmt.Fprintln(os.Stderr, "atomic: cannot execute "+args[0]+": "+err.Error())
+		os.Exit(127)
+	}
+
+	// the command is reparented to the helper once the shell exits, instead of to init
+	if err := unix.Prctl(unix.PR_SET_CHILD_SUBREAPER, 1, 0, 0, 0); err != nil {
+		fail(err)
+	}
+	pidRead, pidWrite, err := os.Pipe()
+	if err != nil {
+		fail(err)
+	}
+	script := measureHelperScript
+	// the shell exports a PWD of its own otherwise
+	if _, ok := os.LookupEnv("PWD"); !ok {
+		script = "unset PWD\n" + script
+	}
+	shell := exec.Command("/bin/sh", append([]string{"-c", script, "sh"}, args...)...)
+	shell.Stdin = os.Stdin
+	shell.Stdout = os.Stdout
+	shell.Stderr = os.Stderr
+	shell.ExtraFiles = []*os.File{release, pidWrite}
+	// the command stays in the group of the shell, so that atomic can stop it without stopping the helper
+	shell.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := shell.Run(); err != nil {
+		fail(err)
+	}
+	pidWrite.Close()
+	var pid int
+	if _, err := fmt.Fscan(pidRead, &pid); err != nil {
+		fail(err)
+	}
+	binary.Write(report, binary.LittleEndian, [2]int64{int64(pid), int64(shell.Process.Pid)})
+
+	var status syscall.WaitStatus
+	var rusage syscall.Rusage
+	for {
+		_, err = syscall.Wait4(pid, &status, 0, &rusage)
+		if !errors.Is(err, syscall.EINTR) {
+			break
+		}
+	}
+	if err != nil {
+		fail(err)
+	}
+	binary.Write(report, binary.LittleEndian, uint32(status))
+	binary.Write(report, binary.LittleEndian, &rusage)
+	os.Exit(0)
+}
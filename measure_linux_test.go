@@ -0,0 +1,92 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	// the test binary is started as the measuring helper, as atomic is
+	if len(os.Args) > 1 && os.Args[1] == measureHelperArg {
+		runMeasureHelper(os.Args[2:])
+	}
+	os.Exit(m.Run())
+}
+
+// runs the shell script through the measuring helper, the failures of the script being ignored
+func runMeasured(t *testing.T, script string, timeout time.Duration) *RunResult {
+	t.Helper()
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no POSIX shell available")
+	}
+	result := RunCommand(&RunOptions{
+		command:       []string{sh, "-c", script},
+		output:        nullOutput,
+		ignoreError:   true,
+		timeout:       timeout,
+		recordTimeout: true,
+		exactMemory:   true,
+	})
+	if result.err != nil {
+		t.Fatalf("RunCommand(`%s`) error = %v", script, result.err)
+	}
+	return result
+}
+
+// the memory the test touches before starting the commands, which mustn't be counted towards them
+var ballast []byte
+
+func TestRunCommandExactMemory(t *testing.T) {
+	const ballastSize = 64 << 20
+	ballast = make([]byte, ballastSize)
+	for i := 0; i < len(ballast); i += os.Getpagesize() {
+		ballast[i] = 1
+	}
+	truePath, err := exec.LookPath("true")
+	if err != nil {
+		t.Skip("no true command available")
+	}
+	opts := RunOptions{command: []string{truePath}, output: nullOutput, timeout: LargestDuration}
+
+	// started directly, the command is reported to use the memory of the test too
+	direct := opts
+	if result := RunCommand(&direct); result.err != nil || result.maxRSS < ballastSize {
+		t.Errorf("RunCommand() = %d bytes, error %v, want at least the %d bytes of the ballast", result.maxRSS, result.err, ballastSize)
+	}
+	exact := opts
+	exact.exactMemory = true
+	if result := RunCommand(&exact); result.err != nil || result.maxRSS == 0 || result.maxRSS >= ballastSize/4 {
+		t.Errorf("RunCommand() with exact memory = %d bytes, error %v, want far less than the %d bytes of the ballast", result.maxRSS, result.err, ballastSize)
+	}
+}
+
+func TestRunCommandExitStatus(t *testing.T) {
+	tests := []struct {
+		script   string
+		exitCode int
+		signal   string
+	}{
+		{"exit 0", 0, ""},
+		{"exit 3", 3, ""},
+		{"kill -KILL $$", -1, "killed"},
+	}
+	for _, tt := range tests {
+		result := runMeasured(t, tt.script, LargestDuration)
+		if result.exitCode != tt.exitCode || result.signal != tt.signal {
+			t.Errorf("RunCommand(`%s`) = exit code %d, signal %q, want %d, %q", tt.script, result.exitCode, result.signal, tt.exitCode, tt.signal)
+		}
+	}
+}
+
+func TestRunCommandRecordsTimeout(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+	result := runMeasured(t, "sleep 10", timeout)
+	if !result.timedOut || result.elapsed != timeout || result.signal != "terminated" {
+		t.Errorf("RunCommand() = timed out %v, elapsed %v, signal %q, want a run lasting the timeout, terminated by SIGTERM", result.timedOut, result.elapsed, result.signal)
+	}
+}
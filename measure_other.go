@@ -0,0 +1,56 @@
+//go:build !linux
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"time"
+)
+
+const measureHelperArg = "__atomic_measure"
+
+// measurement starts a command and tells how it exited. The peak memory usage of a process isn't inflated
+// by the memory of its parent outside of Linux, so the command is always started as it is.
+type measurement struct {
+	cmd    *exec.Cmd
+	group  *os.Process
+	pid    int
+	exited time.Time
+}
+
+func prepareMeasurement(cmd *exec.Cmd, exact bool) (*measurement, error) {
+	return &measurement{cmd: cmd}, nil
+}
+
+func (m *measurement) started() {}
+
+func (m *measurement) ready() error {
+	m.group = m.cmd.Process
+	m.pid = m.cmd.Process.Pid
+	return nil
+}
+
+func (m *measurement) held() bool {
+	return false
+}
+
+func (m *measurement) start() error {
+	return nil
+}
+
+func (m *measurement) wait() error {
+	err := m.cmd.Wait()
+	m.exited = time.Now()
+	return err
+}
+
+func (m *measurement) state() processState {
+	return m.cmd.ProcessState
+}
+
+func (m *measurement) close() {}
+
+func runMeasureHelper(args []string) {
+	os.Exit(127)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// outputMode tells what is done with the output (both stdout and stderr) of a command.
+// Any value other than the constants below is the path of a file the output is written to.
+type outputMode string
+
+const (
+	// the output is discarded by redirecting it to the null device
+	nullOutput outputMode = "null"
+	// the output is drained through a pipe by atomic, which counts the bytes
+	pipeOutput outputMode = "pipe"
+	// the output is shown in the terminal
+	inheritOutput outputMode = "inherit"
+)
+
+// parses the value of the --output flag, making sure that a file can be created at the given path.
+func parseOutputMode(output string) (outputMode, error) {
+	switch mode := outputMode(output); mode {
+	case nullOutput, pipeOutput, inheritOutput:
+		return mode, nil
+	default:
+		f, err := os.Create(output)
+		if err != nil {
+			return "", fmt.Errorf("unable to create the output file: %w", err)
+		}
+		f.Close()
+		return mode, nil
+	}
+}
+
+// returns the output mode for the commands whose output is only shown in verbose mode
+func verboseOutput(verbose bool) outputMode {
+	if verbose {
+		return inheritOutput
+	}
+	return nullOutput
+}
+
+// byteCounter is an [io.Writer] that discards everything written to it, only counting the bytes.
+type byteCounter struct {
+	n int64
+}
+
+func (bc *byteCounter) Write(p []byte) (int, error) {
+	bc.n += int64(len(p))
+	return len(p), nil
+}
+
+// setOutput redirects the output of the command as per the mode. The returned function must be
+// called once the command has exited, it closes the output file if any and returns the number of
+// bytes the command has written, which is only counted for [pipeOutput].
+func setOutput(cmd *exec.Cmd, mode outputMode) (func() int64, error) {
+	switch mode {
+	case "", nullOutput:
+		// exec.Cmd connects nil stdout and stderr to the null device
+		return func() int64 { return 0 }, nil
+	case inheritOutput:
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		return func() int64 { return 0 }, nil
+	case pipeOutput:
+		// since both are the same writer, exec.Cmd shares a single pipe between them and
+		// waits for it to be drained before returning from Wait
+		counter := &byteCounter{}
+		cmd.Stdout = counter
+		cmd.Stderr = counter
+		return func() int64 { return counter.n }, nil
+	default:
+		// truncate the file in every run, so that every run writes the same amount of data to it
+		f, err := os.Create(string(mode))
+		if err != nil {
+			return nil, err
+		}
+		cmd.Stdout = f
+		cmd.Stderr = f
+		return func() int64 {
+			f.Close()
+			return 0
+		}, nil
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseOutputMode(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "output")
+	tests := []struct {
+		output  string
+		want    outputMode
+		wantErr bool
+	}{
+		{"null", nullOutput, false},
+		{"pipe", pipeOutput, false},
+		{"inherit", inheritOutput, false},
+		{file, outputMode(file), false},
+		{filepath.Join(dir, "missing", "output"), "", true},
+	}
+	for _, tt := range tests {
+		got, err := parseOutputMode(tt.output)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("parseOutputMode(%q) error = %v, wantErr %v", tt.output, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("parseOutputMode(%q) = %q, want %q", tt.output, got, tt.want)
+		}
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Errorf("parseOutputMode(%q) didn't create the file: %v", file, err)
+	}
+}
+
+// runs the shell script with its output redirected as per the mode, returning the counted bytes
+func runWithOutput(t *testing.T, script string, mode outputMode) (*exec.Cmd, int64) {
+	t.Helper()
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no POSIX shell available")
+	}
+	cmd := exec.Command(sh, "-c", script)
+	done, err := setOutput(cmd, mode)
+	if err != nil {
+		t.Fatalf("setOutput(%q) error = %v", mode, err)
+	}
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("running `%s`: %v", script, err)
+	}
+	return cmd, done()
+}
+
+func TestSetOutput(t *testing.T) {
+	const script = "printf abc; printf de >&2"
+
+	cmd, n := runWithOutput(t, script, nullOutput)
+	if cmd.Stdout != nil || cmd.Stderr != nil || n != 0 {
+		t.Errorf("null output: stdout %v, stderr %v, %d bytes, want the null device and 0 bytes", cmd.Stdout, cmd.Stderr, n)
+	}
+
+	cmd = exec.Command("true")
+	if _, err := setOutput(cmd, inheritOutput); err != nil || cmd.Stdout != os.Stdout || cmd.Stderr != os.Stderr {
+		t.Errorf("inherit output: stdout %v, stderr %v, error %v, want the terminal", cmd.Stdout, cmd.Stderr, err)
+	}
+
+	// both stdout and stderr are counted
+	if _, n := runWithOutput(t, script, pipeOutput); n != 5 {
+		t.Errorf("pipe output counted %d bytes, want 5", n)
+	}
+}
+
+func TestSetOutputTruncatesFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "output")
+	if err := os.WriteFile(file, []byte("left over from before the benchmark\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, script := range []string{"echo first run; echo error >&2", "echo second"} {
+		if _, n := runWithOutput(t, script, outputMode(file)); n != 0 {
+			t.Errorf("file output counted %d bytes, want 0", n)
+		}
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "second\n" {
+		t.Errorf("output file = %q, want %q", content, "second\n")
+	}
+}
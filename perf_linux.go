@@ -0,0 +1,164 @@
+//go:build linux
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/shravanasati/atomic/internal"
+	"golang.org/x/sys/unix"
+)
+
+type perfEvent struct {
+	kind   uint32
+	config uint64
+}
+
+// all the counters which can be given to the --perf-counters flag
+var perfEvents = map[string]perfEvent{
+	"instructions":     {unix.PERF_TYPE_HARDWARE, unix.PERF_COUNT_HW_INSTRUCTIONS},
+	"cycles":           {unix.PERF_TYPE_HARDWARE, unix.PERF_COUNT_HW_CPU_CYCLES},
+	"branches":         {unix.PERF_TYPE_HARDWARE, unix.PERF_COUNT_HW_BRANCH_INSTRUCTIONS},
+	"branch-misses":    {unix.PERF_TYPE_HARDWARE, unix.PERF_COUNT_HW_BRANCH_MISSES},
+	"cache-references": {unix.PERF_TYPE_HARDWARE, unix.PERF_COUNT_HW_CACHE_REFERENCES},
+	"cache-misses":     {unix.PERF_TYPE_HARDWARE, unix.PERF_COUNT_HW_CACHE_MISSES},
+	"task-clock":       {unix.PERF_TYPE_SOFTWARE, unix.PERF_COUNT_SW_TASK_CLOCK},
+	"cpu-clock":        {unix.PERF_TYPE_SOFTWARE, unix.PERF_COUNT_SW_CPU_CLOCK},
+	"context-switches": {unix.PERF_TYPE_SOFTWARE, unix.PERF_COUNT_SW_CONTEXT_SWITCHES},
+	"cpu-migrations":   {unix.PERF_TYPE_SOFTWARE, unix.PERF_COUNT_SW_CPU_MIGRATIONS},
+	"page-faults":      {unix.PERF_TYPE_SOFTWARE, unix.PERF_COUNT_SW_PAGE_FAULTS},
+}
+
+// the counters used in place of the hardware counters when those aren't available
+var fallbackPerfCounters = []string{"task-clock", "context-switches", "cpu-migrations"}
+
+// whether kernel space is excluded from counting, set when the kernel only allows counting user space
+var perfUserSpaceOnly bool
+
+func perfEventAttr(event perfEvent) *unix.PerfEventAttr {
+	attr := &unix.PerfEventAttr{
+		Type:        event.kind,
+		Config:      event.config,
+		Read_format: unix.PERF_FORMAT_TOTAL_TIME_ENABLED | unix.PERF_FORMAT_TOTAL_TIME_RUNNING,
+		Bits:        unix.PerfBitDisabled | unix.PerfBitInherit | unix.PerfBitEnableOnExec,
+	}
+	if perfUserSpaceOnly {
+		attr.Bits |= unix.PerfBitExcludeKernel | unix.PerfBitExcludeHv
+	}
+	attr.Size = uint32(binary.Size(attr))
+	return attr
+}
+
+// tries to open the counter on atomic itself, to know whether it can be measured at all
+func probePerfCounter(name string) error {
+	fd, err := unix.PerfEventOpen(perfEventAttr(perfEvents[name]), 0, -1, -1, unix.PERF_FLAG_FD_CLOEXEC)
+	if err != nil {
+		return err
+	}
+	return unix.Close(fd)
+}
+
+// parsePerfCounters parses the value of the --perf-counters flag and makes sure that every
+// counter can be opened. When the hardware counters aren't available (e.g. in virtual machines),
+// they're replaced by software counters and a note is printed.
+func parsePerfCounters(spec string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(strings.ToLower(name))
+		if name == "" || slices.Contains(names, name) {
+			continue
+		}
+		if _, ok := perfEvents[name]; !ok {
+			available := make([]string, 0, len(perfEvents))
+			for n := range perfEvents {
+				available = append(available, n)
+			}
+			slices.Sort(available)
+			return nil, fmt.Errorf("unknown perf counter `%s`, available counters are: %s", name, strings.Join(available, ", "))
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, errors.New("no perf counters given")
+	}
+
+	// a perf_event_paranoid of 2 only lets unprivileged users count user space
+	if err := probePerfCounter("task-clock"); errors.Is(err, unix.EACCES) || errors.Is(err, unix.EPERM) {
+		perfUserSpaceOnly = true
+		if err = probePerfCounter("task-clock"); err != nil {
+			return nil, fmt.Errorf("perf counters aren't permitted (see /proc/sys/kernel/perf_event_paranoid): %w", err)
+		}
+		internal.Log("yellow", "Note: the kernel only permits counting user space, kernel space is excluded from the perf counters.")
+	} else if err != nil {
+		return nil, fmt.Errorf("perf counters aren't available: %w", err)
+	}
+
+	var unavailable []string
+	for _, name := range names {
+		if perfEvents[name].kind == unix.PERF_TYPE_HARDWARE && probePerfCounter(name) != nil {
+			unavailable = append(unavailable, name)
+		}
+	}
+	if len(unavailable) != 0 {
+		names = internal.FilterFunc(func(name string) bool { return !slices.Contains(unavailable, name) }, names)
+		for _, name := range fallbackPerfCounters {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+		internal.Log("yellow", "Note: the hardware counters ("+strings.Join(unavailable, ", ")+") aren't available on this machine (as is common in virtual machines), falling back to the software counters "+strings.Join(names, ", ")+".")
+	}
+	return names, nil
+}
+
+// perfSession holds the counters measuring a single run of a command. The counters are opened on the command
+// before it executes and they're enabled by the exec, so that nothing before it is counted, see [measurement].
+type perfSession struct {
+	names []string
+	fds   []int
+}
+
+// openPerfSession opens the counters on the process which is about to execute the command.
+func openPerfSession(pid int, names []string) (*perfSession, error) {
+	ps := &perfSession{names: names}
+	for _, name := range names {
+		fd, err := unix.PerfEventOpen(perfEventAttr(perfEvents[name]), pid, -1, -1, unix.PERF_FLAG_FD_CLOEXEC)
+		if err != nil {
+			ps.close()
+			return nil, fmt.Errorf("cannot open the perf counter %s: %w", name, err)
+		}
+		ps.fds = append(ps.fds, fd)
+	}
+	return ps, nil
+}
+
+// read returns the values of all the counters, scaled up if the kernel had to multiplex them.
+func (ps *perfSession) read() (map[string]float64, error) {
+	values := make(map[string]float64, len(ps.fds))
+	for i, fd := range ps.fds {
+		// value, time enabled, time running
+		var buf [24]byte
+		if _, err := unix.Read(fd, buf[:]); err != nil {
+			return nil, fmt.Errorf("cannot read the perf counter %s: %w", ps.names[i], err)
+		}
+		value := float64(binary.LittleEndian.Uint64(buf[0:]))
+		enabled := float64(binary.LittleEndian.Uint64(buf[8:]))
+		running := float64(binary.LittleEndian.Uint64(buf[16:]))
+		if running > 0 && running < enabled {
+			value *= enabled / running
+		}
+		values[ps.names[i]] = value
+	}
+	return values, nil
+}
+
+// close releases the counters.
+func (ps *perfSession) close() {
+	for _, fd := range ps.fds {
+		unix.Close(fd)
+	}
+}
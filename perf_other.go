@@ -0,0 +1,23 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+var errPerfUnsupported = errors.New("perf counters are only available on linux")
+
+func parsePerfCounters(spec string) ([]string, error) {
+	return nil, errPerfUnsupported
+}
+
+type perfSession struct{}
+
+func openPerfSession(pid int, names []string) (*perfSession, error) {
+	return nil, errPerfUnsupported
+}
+
+func (ps *perfSession) read() (map[string]float64, error) {
+	return nil, errPerfUnsupported
+}
+
+func (ps *perfSession) close() {}
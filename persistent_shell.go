@@ -0,0 +1,390 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// shellExecution tells how the commands are executed through the shell with the -s/--shell flag, as set by --shell-mode.
+type shellExecution string
+
+const (
+	// a shell is spawned for every run, and its average spawn time is subtracted from the runs
+	spawnExecution shellExecution = "spawn"
+	// a single long-lived shell executes all the runs, see [persistentShell]
+	persistentExecution shellExecution = "persistent"
+)
+
+func parseShellExecution(mode string) (shellExecution, error) {
+	switch m := shellExecution(strings.ToLower(strings.TrimSpace(mode))); m {
+	case spawnExecution, persistentExecution:
+		return m, nil
+	default:
+		return "", fmt.Errorf("invalid shell mode `%s`, expected spawn or persistent", mode)
+	}
+}
+
+// persistentShell is a long-lived POSIX shell which executes the runs of a command one after another.
+// Every run is submitted as a line of script over the shell's stdin, which makes the shell write a marker
+// to a control pipe (its fd 3) right before and right after executing the command. The run is timed
+// between the markers, so that neither the spawn of the shell nor the submission of the script is measured.
+// Shells which provide $EPOCHREALTIME (bash 5, mksh, zsh) timestamp the markers themselves. With the other
+// shells, the markers are timestamped as they're read, which adds the latency of reading them to the runs.
+type persistentShell struct {
+	cmd    *exec.Cmd
+	script io.WriteCloser
+	// the read end of the control pipe, read without buffering so that every marker is timestamped on arrival
+	control *os.File
+	// counts the bytes the shell writes for [pipeOutput], nil otherwise
+	counter *runOutputCounter
+	// the file the shell writes to, truncated before every run, nil if the output isn't written to a file
+	outputFile   *os.File
+	finishOutput func() int64
+}
+
+// shellMarker is a line the shell writes to the control pipe: `ready <status>` once the init script has been
+// evaluated, `start [<time>]` right before the command and `end <status> [<time>]` right after it.
+type shellMarker struct {
+	kind string
+	// the exit status of the init script or of the command
+	status int
+	// when the shell wrote the marker, as the time since the Unix epoch, zero if the shell can't tell
+	written time.Duration
+	// when atomic read the marker
+	read time.Time
+}
+
+// parses a line written to the control pipe, the time it was read at is left unset
+func parseShellMarker(line string) (shellMarker, error) {
+	fields := strings.Fields(line)
+	invalid := fmt.Errorf("unexpected marker `%s` from the shell", line)
+	if len(fields) == 0 {
+		return shellMarker{}, invalid
+	}
+	marker := shellMarker{kind: fields[0]}
+	args := fields[1:]
+	switch marker.kind {
+	case "ready", "end":
+		if len(args) == 0 {
+			return shellMarker{}, invalid
+		}
+		status, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shellMarker{}, invalid
+		}
+		marker.status = status
+		args = args[1:]
+	case "start":
+	default:
+		return shellMarker{}, invalid
+	}
+	switch len(args) {
+	case 0:
+	case 1:
+		written, err := parseEpochRealtime(args[0])
+		if err != nil {
+			return shellMarker{}, invalid
+		}
+		marker.written = written
+	default:
+		return shellMarker{}, invalid
+	}
+	return marker, nil
+}
+
+// parses a value of $EPOCHREALTIME, the seconds since the Unix epoch with a fractional part, whose
+// separator is the decimal point of the shell's locale
+func parseEpochRealtime(value string) (time.Duration, error) {
+	seconds, fraction, _ := strings.Cut(strings.Replace(value, ",", ".", 1), ".")
+	secs, err := strconv.ParseInt(seconds, 10, 64)
+	if err != nil || secs < 0 {
+		return 0, fmt.Errorf("invalid time `%s`", value)
+	}
+	fraction = (fraction + "000000000")[:9]
+	nanos, err := strconv.ParseInt(fraction, 10, 64)
+	if err != nil || nanos < 0 {
+		return 0, fmt.Errorf("invalid time `%s`", value)
+	}
+	return time.Duration(secs)*time.Second + time.Duration(nanos), nil
+}
+
+// the time between the start and end markers of a run, as measured by the shell if it timestamped both
+func markedElapsed(start, end shellMarker) time.Duration {
+	if start.written != 0 && end.written != 0 {
+		return end.written - start.written
+	}
+	return end.read.Sub(start.read)
+}
+
+// the bytes a persistent shell writes to its output after every run with [pipeOutput], which tell where
+// the output of a run ends, since it may still be in the pipe when the run is over
+const outputSentinel = "\x00atomic-run-end\x01"
+
+// runOutputCounter is an [io.Writer] which counts the bytes a persistent shell writes, run by run.
+// The count of a run is sent over `runs` once its [outputSentinel] is written.
+type runOutputCounter struct {
+	count int64
+	// number of bytes of the sentinel written so far, the sentinel doesn't repeat its first byte
+	matched int
+	runs    chan int64
+}
+
+func (c *runOutputCounter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		if b == outputSentinel[c.matched] {
+			c.matched++
+			if c.matched == len(outputSentinel) {
+				// the count of a run whose result isn't waited for anymore (a failed run) is dropped
+				select {
+				case c.runs <- c.count:
+				default:
+				}
+				c.count, c.matched = 0, 0
+			}
+			continue
+		}
+		// the bytes which looked like the start of the sentinel are output after all
+		c.count += int64(c.matched)
+		c.matched = 0
+		if b == outputSentinel[0] {
+			c.matched = 1
+		} else {
+			c.count++
+		}
+	}
+	return len(p), nil
+}
+
+// startPersistentShell starts a shell with the given arguments, which must make it read its script from stdin,
+// and waits until it has evaluated the init script, if any. The shell starts in the given directory, if any.
+func startPersistentShell(args []string, init string, output outputMode, cpus []int, env []string, dir string, timeout time.Duration) (*persistentShell, error) {
+	cmd := exec.Command(args[0], args[1:]...)
+	// the commands are executed in the process group of the shell, which is stopped as a whole
+	setProcessGroup(cmd)
+	cmd.Env = env
+	cmd.Dir = dir
+	// background processes left by the commands may hold on to the output after the shell is gone
+	cmd.WaitDelay = time.Second
+	var counter *runOutputCounter
+	var outputFile *os.File
+	finishOutput := func() int64 { return 0 }
+	switch output {
+	case pipeOutput:
+		counter = &runOutputCounter{runs: make(chan int64, 1)}
+		cmd.Stdout = counter
+		cmd.Stderr = counter
+	case "", nullOutput, inheritOutput:
+		var err error
+		if finishOutput, err = setOutput(cmd, output); err != nil {
+			return nil, err
+		}
+	default:
+		// the shell appends to the file, so that it writes at the start of the file once it's truncated
+		var err error
+		if outputFile, err = os.OpenFile(string(output), os.O_WRONLY|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0o666); err != nil {
+			return nil, err
+		}
+		cmd.Stdout = outputFile
+		cmd.Stderr = outputFile
+		finishOutput = func() int64 {
+			outputFile.Close()
+			return 0
+		}
+	}
+	script, err := cmd.StdinPipe()
+	if err != nil {
+		finishOutput()
+		return nil, err
+	}
+	controlRead, controlWrite, err := os.Pipe()
+	if err != nil {
+		finishOutput()
+		return nil, err
+	}
+	cmd.ExtraFiles = []*os.File{controlWrite}
+	err = interrupts.start(cmd, cpus)
+	controlWrite.Close()
+	if err != nil {
+		controlRead.Close()
+		finishOutput()
+		return nil, err
+	}
+	ps := &persistentShell{
+		cmd:          cmd,
+		script:       script,
+		control:      controlRead,
+		counter:      counter,
+		outputFile:   outputFile,
+		finishOutput: finishOutput,
+	}
+
+	// the marker also tells that the shell is up and running
+	if init == "" {
+		init = ":"
+	}
+	ps.control.SetReadDeadline(time.Now().Add(timeout))
+	// zsh only provides $EPOCHREALTIME once its datetime module is loaded
+	_, err = io.WriteString(ps.script, fmt.Sprintf("[ -n \"${ZSH_VERSION-}\" ] && zmodload zsh/datetime 2>/dev/null; eval %s </dev/null 3>&-; printf 'ready %%d\\n' \"$?\" >&3\n", posixQuote(init)))
+	if err == nil {
+		var marker shellMarker
+		if marker, err = ps.nextMarker(); err == nil {
+			if marker.kind != "ready" {
+				err = fmt.Errorf("unexpected `%s` marker from the shell", marker.kind)
+			} else if marker.status != 0 {
+				err = fmt.Errorf("the init script exited with status %d", marker.status)
+			}
+		}
+	}
+	if err != nil {
+		ps.close()
+		return nil, err
+	}
+	return ps, nil
+}
+
+// quotes the string for a POSIX shell
+func posixQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// reads the next marker from the control pipe, a byte at a time so that it's read as soon as it's written
+func (ps *persistentShell) nextMarker() (shellMarker, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		if _, err := ps.control.Read(b); err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				return shellMarker{}, context.DeadlineExceeded
+			}
+			return shellMarker{}, errors.New("the shell exited unexpectedly, commands must not exit it")
+		}
+		if b[0] == '\n' {
+			break
+		}
+		line = append(line, b[0])
+	}
+	read := time.Now()
+	marker, err := parseShellMarker(string(line))
+	marker.read = read
+	return marker, err
+}
+
+// run executes the command in the shell, feeding it the input file if any, and returns the result of the run.
+// The shell changes to the given directory first, if any, which isn't measured. The run is given up when `stop`
+// is closed, in which case [errInterrupted] is returned.
+// The command is evaluated in the shell itself, so it can use the functions and aliases defined by the
+// previous commands. Neither the user and system times nor the memory usage of a run are available.
+// The shell can't be used anymore once an error is returned.
+func (ps *persistentShell) run(command, input, dir string, timeout time.Duration, stop <-chan struct{}) (*RunResult, error) {
+	if input == "" {
+		input = "/dev/null"
+	}
+	cd := ""
+	if dir != "" {
+		cd = fmt.Sprintf("cd %s 3>&- || exit; ", posixQuote(dir))
+	}
+	// deadlines aren't supported by the pipes of every platform, in which case runs can't time out
+	ps.control.SetReadDeadline(time.Now().Add(timeout))
+	// an interrupt expires the deadline right away
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-stop:
+			ps.control.SetReadDeadline(time.Now())
+		case <-done:
+		}
+	}()
+	interrupted := func(err error) error {
+		select {
+		case <-stop:
+			return errInterrupted
+		default:
+			return err
+		}
+	}
+	if ps.outputFile != nil {
+		if err := ps.outputFile.Truncate(0); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := io.WriteString(ps.script, cd+runScript(command, input, ps.counter != nil)); err != nil {
+		return nil, err
+	}
+	start, err := ps.nextMarker()
+	if err != nil {
+		return nil, interrupted(err)
+	} else if start.kind != "start" {
+		return nil, fmt.Errorf("unexpected `%s` marker from the shell", start.kind)
+	}
+	end, err := ps.nextMarker()
+	if err != nil {
+		return nil, interrupted(err)
+	} else if end.kind != "end" {
+		return nil, fmt.Errorf("unexpected `%s` marker from the shell", end.kind)
+	}
+	runResult := emptyRunResult()
+	runResult.exitCode = end.status
+	runResult.elapsed = markedElapsed(start, end)
+	if ps.counter != nil {
+		select {
+		case runResult.outputBytes = <-ps.counter.runs:
+		case <-time.After(time.Second):
+			return nil, errors.New("the output of the run didn't end")
+		}
+	}
+	return runResult, nil
+}
+
+// runScript returns the line of script which makes the shell execute a run of the command, fed the input file.
+// The shell writes the start and end markers around the command, and the [outputSentinel] after its output
+// if `sentinel` is set. Nothing but reading $EPOCHREALTIME happens between the timestamps of the markers.
+func runScript(command, input string, sentinel bool) string {
+	end := `printf 'end %d %s\n' "$?" "${EPOCHREALTIME-}" >&3`
+	if sentinel {
+		end = `__atomic_end="$? ${EPOCHREALTIME-}"; printf '\000atomic-run-end\001'; printf 'end %s\n' "$__atomic_end" >&3`
+	}
+	return fmt.Sprintf(`printf 'start %%s\n' "${EPOCHREALTIME-}" >&3; eval %s <%s 3>&-; %s`+"\n", posixQuote(command), posixQuote(input), end)
+}
+
+// waits for the shell to exit in the background, the returned channel is closed once it has
+func (ps *persistentShell) wait() <-chan struct{} {
+	exited := make(chan struct{})
+	go func() {
+		ps.cmd.Wait()
+		close(exited)
+	}()
+	return exited
+}
+
+// close makes the shell exit, stopping it if it doesn't, and releases its pipes and output.
+// The processes left in the background by the commands are killed along with the shell.
+func (ps *persistentShell) close() {
+	defer interrupts.exited(ps.cmd.Process)
+	ps.script.Close()
+	exited := ps.wait()
+	select {
+	case <-exited:
+		killProcessGroup(ps.cmd.Process)
+	case <-time.After(killGracePeriod):
+		stopProcessGroup(ps.cmd.Process, exited)
+	}
+	ps.control.Close()
+	ps.finishOutput()
+}
+
+// kill stops the shell along with the command it's executing, e.g. after a timeout, and releases its pipes and output.
+func (ps *persistentShell) kill() {
+	defer interrupts.exited(ps.cmd.Process)
+	ps.script.Close()
+	stopProcessGroup(ps.cmd.Process, ps.wait())
+	ps.control.Close()
+	ps.finishOutput()
+}
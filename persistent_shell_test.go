@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRunOutputCounter(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		want   []int64
+	}{
+		{"single run", []string{"hello\n" + outputSentinel}, []int64{6}},
+		{"sentinel split across writes", []string{"abc\x00atomic-", "run-end\x01"}, []int64{3}},
+		{"byte by byte", splitBytes("ab" + outputSentinel), []int64{2}},
+		{"partial sentinel is output", []string{"\x00atomic-run\x00atomic-run-end\x01"}, []int64{11}},
+		{"partial sentinel at the end of a write", []string{"x\x00atom", "ic" + outputSentinel}, []int64{8}},
+		{"empty run", []string{outputSentinel}, []int64{0}},
+		{"two runs", []string{"a" + outputSentinel + "bc" + outputSentinel}, []int64{1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter := &runOutputCounter{runs: make(chan int64, len(tt.want))}
+			for _, w := range tt.writes {
+				if n, err := counter.Write([]byte(w)); n != len(w) || err != nil {
+					t.Fatalf("Write(%q) = %d, %v", w, n, err)
+				}
+			}
+			close(counter.runs)
+			var got []int64
+			for count := range counter.runs {
+				got = append(got, count)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("counts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunOutputCounterDropsUnreadCounts(t *testing.T) {
+	counter := &runOutputCounter{runs: make(chan int64, 1)}
+	counter.Write([]byte("a" + outputSentinel + "bc" + outputSentinel + "def"))
+	if got := <-counter.runs; got != 1 {
+		t.Errorf("first count = %d, want 1", got)
+	}
+	// the count of the second run was dropped since nobody waited for it, the third run is still counted
+	counter.Write([]byte(outputSentinel))
+	if got := <-counter.runs; got != 3 {
+		t.Errorf("third count = %d, want 3", got)
+	}
+}
+
+func splitBytes(s string) []string {
+	var parts []string
+	for i := range s {
+		parts = append(parts, s[i:i+1])
+	}
+	return parts
+}
+
+func TestParseShellMarker(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    shellMarker
+		wantErr bool
+	}{
+		{"ready 0", shellMarker{kind: "ready"}, false},
+		{"ready 2", shellMarker{kind: "ready", status: 2}, false},
+		{"start ", shellMarker{kind: "start"}, false},
+		{"start 1700000000.000250", shellMarker{kind: "start", written: 1700000000*time.Second + 250*time.Microsecond}, false},
+		{"end 3 ", shellMarker{kind: "end", status: 3}, false},
+		{"end 0 1700000000,5", shellMarker{kind: "end", written: 1700000000*time.Second + 500*time.Millisecond}, false},
+		{"end", shellMarker{}, true},
+		{"end x", shellMarker{}, true},
+		{"start soon", shellMarker{}, true},
+		{"start 1 2", shellMarker{}, true},
+		{"hello", shellMarker{}, true},
+		{"", shellMarker{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseShellMarker(tt.line)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("parseShellMarker(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("parseShellMarker(%q) = %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestMarkedElapsed(t *testing.T) {
+	read := time.Now()
+	tests := []struct {
+		name       string
+		start, end shellMarker
+		want       time.Duration
+	}{
+		{"timestamped by the shell", shellMarker{written: time.Second, read: read}, shellMarker{written: time.Second + 40*time.Microsecond, read: read}, 40 * time.Microsecond},
+		{"timestamped on arrival", shellMarker{read: read}, shellMarker{read: read.Add(time.Millisecond)}, time.Millisecond},
+		{"only one timestamp", shellMarker{written: time.Second, read: read}, shellMarker{read: read.Add(time.Millisecond)}, time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := markedElapsed(tt.start, tt.end); got != tt.want {
+			t.Errorf("%s: markedElapsed() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// starts a persistent sh, skipping the test where there's none
+func startTestShell(t *testing.T, init string, output outputMode) *persistentShell {
+	t.Helper()
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no POSIX shell available")
+	}
+	ps, err := startPersistentShell([]string{sh, "-s"}, init, output, nil, nil, "", 5*time.Second)
+	if err != nil {
+		t.Fatalf("startPersistentShell() error = %v", err)
+	}
+	return ps
+}
+
+func TestPersistentShellRun(t *testing.T) {
+	ps := startTestShell(t, "greet() { echo hello; return 3; }", pipeOutput)
+	defer ps.close()
+	tests := []struct {
+		command   string
+		exitCode  int
+		bytes     int64
+		minElapse time.Duration
+	}{
+		{"greet", 3, 6, 0},
+		{"printf abc; false", 1, 3, 0},
+		{"sleep 0.1", 0, 0, 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		result, err := ps.run(tt.command, "", "", 5*time.Second, nil)
+		if err != nil {
+			t.Fatalf("run(%q) error = %v", tt.command, err)
+		}
+		if result.exitCode != tt.exitCode || result.outputBytes != tt.bytes || result.elapsed < tt.minElapse {
+			t.Errorf("run(%q) = exit code %d, %d bytes in %v, want exit code %d, %d bytes in at least %v",
+				tt.command, result.exitCode, result.outputBytes, result.elapsed, tt.exitCode, tt.bytes, tt.minElapse)
+		}
+	}
+}
+
+func TestPersistentShellInitFailure(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no POSIX shell available")
+	}
+	if _, err := startPersistentShell([]string{sh, "-s"}, "false", nullOutput, nil, nil, "", 5*time.Second); err == nil {
+		t.Error("startPersistentShell() with a failing init script succeeded")
+	}
+}
+
+func TestPersistentShellTimeout(t *testing.T) {
+	ps := startTestShell(t, "", nullOutput)
+	defer ps.kill()
+	if _, err := ps.run("sleep 5", "", "", 100*time.Millisecond, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("run() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestPersistentShellInterrupt(t *testing.T) {
+	ps := startTestShell(t, "", nullOutput)
+	defer ps.kill()
+	stop := make(chan struct{})
+	time.AfterFunc(100*time.Millisecond, func() { close(stop) })
+	if _, err := ps.run("sleep 5", "", "", 5*time.Second, stop); !errors.Is(err, errInterrupted) {
+		t.Errorf("run() error = %v, want %v", err, errInterrupted)
+	}
+}
+
+func TestPersistentShellTruncatesOutputFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "output")
+	ps := startTestShell(t, "", outputMode(file))
+	defer ps.close()
+	for _, command := range []string{"echo first run", "echo second"} {
+		if _, err := ps.run(command, "", "", 5*time.Second, nil); err != nil {
+			t.Fatalf("run(%q) error = %v", command, err)
+		}
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "second\n" {
+		t.Errorf("output file = %q, want %q", content, "second\n")
+	}
+}
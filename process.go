@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// timeoutAction tells what happens to a run which exceeds the -t/--timeout flag, as set by --on-timeout.
+type timeoutAction string
+
+const (
+	// the benchmark of the command fails
+	failOnTimeout timeoutAction = "fail"
+	// the run is kept as a censored sample, i.e. a run which took at least the timeout
+	recordOnTimeout timeoutAction = "record"
+)
+
+func parseTimeoutAction(action string) (timeoutAction, error) {
+	switch a := timeoutAction(strings.ToLower(strings.TrimSpace(action))); a {
+	case failOnTimeout, recordOnTimeout:
+		return a, nil
+	default:
+		return "", fmt.Errorf("invalid timeout action `%s`, expected fail or record", action)
+	}
+}
+
+// the time a timed-out command is given to exit after being terminated, before it's killed
+const killGracePeriod = 2 * time.Second
+
+// stopProcessGroup terminates the process group the process leads, then kills it if the process hasn't exited
+// after [killGracePeriod]. It returns once `exited` is closed, which must happen when the process has exited.
+func stopProcessGroup(process *os.Process, exited <-chan struct{}) {
+	terminateProcessGroup(process)
+	select {
+	case <-exited:
+	case <-time.After(killGracePeriod):
+	}
+	// also kills the processes of the group which outlived the process
+	killProcessGroup(process)
+	<-exited
+}
+
+// processState tells how a command exited, it's implemented by [os.ProcessState] and by the report of
+// the helper measuring the command, see [measurement].
+type processState interface {
+	ExitCode() int
+	UserTime() time.Duration
+	SystemTime() time.Duration
+	Sys() any
+	SysUsage() any
+}
+
+// waitWithTimeout waits for the started command to exit using `wait`, stopping the process group led by `group`
+// with [stopProcessGroup] if it takes longer than the timeout or if `stop` is closed first. It returns why the
+// command was stopped, [context.DeadlineExceeded] or [errInterrupted], nil if it wasn't, along with the error of `wait`.
+func waitWithTimeout(group *os.Process, wait func() error, timeout time.Duration, stop <-chan struct{}) (stopped error, err error) {
+	exited := make(chan struct{})
+	go func() {
+		err = wait()
+		close(exited)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-exited:
+		return nil, err
+	case <-timer.C:
+		stopped = context.DeadlineExceeded
+	case <-stop:
+		stopped = errInterrupted
+	}
+	stopProcessGroup(group, exited)
+	return stopped, err
+}
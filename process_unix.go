@@ -0,0 +1,70 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+
+	"github.com/shravanasati/atomic/internal"
+)
+
+// returns the name of the signal which terminated the process, empty if it exited normally
+func terminationSignal(state processState) string {
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return status.Signal().String()
+	}
+	return ""
+}
+
+// returns the peak resident set size of the process in bytes
+func peakMemory(state processState) int64 {
+	rusage, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0
+	}
+	// maxrss is in bytes on macOS and in kilobytes everywhere else
+	if runtime.GOOS == "darwin" {
+		return int64(rusage.Maxrss)
+	}
+	return int64(rusage.Maxrss) * 1024
+}
+
+// returns the context switches, page faults and block I/O counts of the process
+func resourceUsage(state processState) internal.ResourceUsage {
+	rusage, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return internal.ResourceUsage{}
+	}
+	return internal.ResourceUsage{
+		VoluntaryContextSwitches:   float64(rusage.Nvcsw),
+		InvoluntaryContextSwitches: float64(rusage.Nivcsw),
+		MinorPageFaults:            float64(rusage.Minflt),
+		MajorPageFaults:            float64(rusage.Majflt),
+		BlockInputs:                float64(rusage.Inblock),
+		BlockOutputs:               float64(rusage.Oublock),
+	}
+}
+
+// the arguments reach the command as they are on unix, there's no command line to build
+func setCommandLine(cmd *exec.Cmd) {}
+
+// makes the command start in a process group of its own, so that the processes it spawns can be stopped along with it
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// sends SIGTERM to the process group the process leads
+func terminateProcessGroup(process *os.Process) {
+	syscall.Kill(-process.Pid, syscall.SIGTERM)
+}
+
+// sends SIGKILL to the process group the process leads
+func killProcessGroup(process *os.Process) {
+	syscall.Kill(-process.Pid, syscall.SIGKILL)
+}
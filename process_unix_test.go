@@ -0,0 +1,144 @@
+//go:build unix
+
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// starts the shell script in a process group of its own, as the benchmarked commands are
+func startInGroup(t *testing.T, script string) *exec.Cmd {
+	t.Helper()
+	cmd := exec.Command("sh", "-c", script)
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("starting `%s`: %v", script, err)
+	}
+	return cmd
+}
+
+// tells whether the process has exited, waiting a bit for it to since signals are delivered asynchronously
+func processGone(pid int) bool {
+	for i := 0; i < 50; i++ {
+		if errors.Is(syscall.Kill(pid, 0), syscall.ESRCH) {
+			return true
+		}
+		// a killed process whose parent exited stays a zombie until it's reaped by init
+		if stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat"); err == nil {
+			if fields := strings.Fields(string(stat)); len(fields) > 2 && (fields[2] == "Z" || fields[2] == "X") {
+				return true
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return false
+}
+
+// reads the pid the script wrote to the file
+func readPid(t *testing.T, file string) int {
+	t.Helper()
+	for i := 0; i < 50; i++ {
+		content, err := os.ReadFile(file)
+		if pid, e := strconv.Atoi(strings.TrimSpace(string(content))); err == nil && e == nil {
+			return pid
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("no pid was written to %s", file)
+	return 0
+}
+
+func TestWaitWithTimeoutExits(t *testing.T) {
+	cmd := startInGroup(t, "exit 3")
+	stopped, err := waitWithTimeout(cmd.Process, cmd.Wait, 5*time.Second, nil)
+	if stopped != nil {
+		t.Errorf("waitWithTimeout() stopped = %v, want nil", stopped)
+	}
+	if cmd.ProcessState.ExitCode() != 3 || err == nil {
+		t.Errorf("waitWithTimeout() = exit code %d, error %v, want exit code 3 and an error", cmd.ProcessState.ExitCode(), err)
+	}
+}
+
+func TestWaitWithTimeoutKillsTheGroup(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	cmd := startInGroup(t, "sleep 10 & echo $! > '"+pidFile+"'; sleep 10")
+	grandchild := readPid(t, pidFile)
+
+	init := time.Now()
+	stopped, _ := waitWithTimeout(cmd.Process, cmd.Wait, 100*time.Millisecond, nil)
+	if !errors.Is(stopped, context.DeadlineExceeded) {
+		t.Errorf("waitWithTimeout() stopped = %v, want %v", stopped, context.DeadlineExceeded)
+	}
+	// sh and sleep exit on SIGTERM, there's no need to wait for the grace period
+	if elapsed := time.Since(init); elapsed >= killGracePeriod {
+		t.Errorf("waitWithTimeout() took %v, the group should have exited on SIGTERM", elapsed)
+	}
+	if status := cmd.ProcessState.Sys().(syscall.WaitStatus); !status.Signaled() || status.Signal() != syscall.SIGTERM {
+		t.Errorf("the command exited with %v, want it terminated by SIGTERM", cmd.ProcessState)
+	}
+	if !processGone(grandchild) {
+		syscall.Kill(grandchild, syscall.SIGKILL)
+		t.Errorf("the background process %d outlived the timeout", grandchild)
+	}
+}
+
+func TestWaitWithTimeoutKillsAfterGracePeriod(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	// the ignored SIGTERM is inherited by the background process too
+	cmd := startInGroup(t, "trap '' TERM; sleep 10 & echo $! > '"+pidFile+"'; sleep 10")
+	grandchild := readPid(t, pidFile)
+
+	init := time.Now()
+	stopped, _ := waitWithTimeout(cmd.Process, cmd.Wait, 100*time.Millisecond, nil)
+	elapsed := time.Since(init)
+	if !errors.Is(stopped, context.DeadlineExceeded) {
+		t.Errorf("waitWithTimeout() stopped = %v, want %v", stopped, context.DeadlineExceeded)
+	}
+	if elapsed < killGracePeriod || elapsed > killGracePeriod+2*time.Second {
+		t.Errorf("waitWithTimeout() took %v, want the %v grace period before SIGKILL", elapsed, killGracePeriod)
+	}
+	if status := cmd.ProcessState.Sys().(syscall.WaitStatus); !status.Signaled() || status.Signal() != syscall.SIGKILL {
+		t.Errorf("the command exited with %v, want it killed by SIGKILL", cmd.ProcessState)
+	}
+	if !processGone(grandchild) {
+		syscall.Kill(grandchild, syscall.SIGKILL)
+		t.Errorf("the background process %d outlived the grace period", grandchild)
+	}
+}
+
+func TestWaitWithTimeoutStops(t *testing.T) {
+	cmd := startInGroup(t, "sleep 10")
+	stop := make(chan struct{})
+	time.AfterFunc(100*time.Millisecond, func() { close(stop) })
+	stopped, _ := waitWithTimeout(cmd.Process, cmd.Wait, 5*time.Second, stop)
+	if !errors.Is(stopped, errInterrupted) {
+		t.Errorf("waitWithTimeout() stopped = %v, want %v", stopped, errInterrupted)
+	}
+}
+
+func TestStopProcessGroupOfExitedLeader(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	// the leader exits right away, leaving its background process in the group
+	cmd := startInGroup(t, "sleep 10 & echo $! > '"+pidFile+"'")
+	grandchild := readPid(t, pidFile)
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+	<-exited
+	stopProcessGroup(cmd.Process, exited)
+	if !processGone(grandchild) {
+		syscall.Kill(grandchild, syscall.SIGKILL)
+		t.Errorf("the background process %d outlived its group being stopped", grandchild)
+	}
+}
@@ -0,0 +1,49 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/shravanasati/atomic/internal"
+)
+
+// processes on windows aren't terminated by signals, so this always returns an empty string
+func terminationSignal(state processState) string {
+	return ""
+}
+
+// peak memory usage isn't reported in the process state on windows, so this always returns zero
+func peakMemory(state processState) int64 {
+	return 0
+}
+
+// rusage isn't available on windows, so this always returns zero counters
+func resourceUsage(state processState) internal.ResourceUsage {
+	return internal.ResourceUsage{}
+}
+
+// makes cmd.exe receive the command string verbatim, see [cmdExeCommandLine]
+func setCommandLine(cmd *exec.Cmd) {
+	if line, ok := cmdExeCommandLine(cmd.Args); ok {
+		if cmd.SysProcAttr == nil {
+			cmd.SysProcAttr = &syscall.SysProcAttr{}
+		}
+		cmd.SysProcAttr.CmdLine = line
+	}
+}
+
+// process groups can't be signalled on windows, so the processes spawned by the command aren't stopped along with it
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// processes can't be asked to terminate on windows, so the process is killed right away
+func terminateProcessGroup(process *os.Process) {
+	process.Kill()
+}
+
+// kills the process, but not the processes it spawned
+func killProcessGroup(process *os.Process) {
+	process.Kill()
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// runOrder is the order in which the runs of several commands are executed.
+type runOrder string
+
+const (
+	// all the runs of a command are executed before those of the next command
+	sequentialOrder runOrder = "sequential"
+	// the commands take turns, one run each
+	interleavedOrder runOrder = "interleaved"
+	// the commands take turns in a random order, which is shuffled anew for every round
+	shuffledOrder runOrder = "shuffled"
+)
+
+func parseRunOrder(order string) (runOrder, error) {
+	switch o := runOrder(strings.ToLower(strings.TrimSpace(order))); o {
+	case sequentialOrder, interleavedOrder, shuffledOrder:
+		return o, nil
+	default:
+		return "", fmt.Errorf("invalid order `%s`, expected one of sequential, interleaved and shuffled", order)
+	}
+}
+
+// schedule returns the order in which the runs are executed, as indexes of the commands,
+// given the number of runs to execute for every command.
+// Both the interleaved and the shuffled orders proceed in rounds of one run of every command which
+// has runs left, so that slow drifts (thermal throttling, background jobs, growing caches) affect all
+// the commands alike. The shuffled order randomly permutes every round using rng.
+func schedule(runs []int, order runOrder, rng *rand.Rand) []int {
+	var indexes []int
+	if order == sequentialOrder {
+		for i, n := range runs {
+			for j := 0; j < n; j++ {
+				indexes = append(indexes, i)
+			}
+		}
+		return indexes
+	}
+
+	for round := 0; ; round++ {
+		var block []int
+		for i, n := range runs {
+			if round < n {
+				block = append(block, i)
+			}
+		}
+		if len(block) == 0 {
+			return indexes
+		}
+		if order == shuffledOrder {
+			rng.Shuffle(len(block), func(a, b int) { block[a], block[b] = block[b], block[a] })
+		}
+		indexes = append(indexes, block...)
+	}
+}
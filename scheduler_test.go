@@ -0,0 +1,87 @@
+package main
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestSchedule(t *testing.T) {
+	tests := []struct {
+		name  string
+		runs  []int
+		order runOrder
+		want  []int
+	}{
+		{"sequential", []int{2, 3}, sequentialOrder, []int{0, 0, 1, 1, 1}},
+		{"sequential without runs", []int{0, 2, 0}, sequentialOrder, []int{1, 1}},
+		{"interleaved", []int{2, 2, 2}, interleavedOrder, []int{0, 1, 2, 0, 1, 2}},
+		{"interleaved with uneven runs", []int{1, 3, 2}, interleavedOrder, []int{0, 1, 2, 1, 2, 1}},
+		{"interleaved without runs", []int{0, 0}, interleavedOrder, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := schedule(tt.runs, tt.order, nil); !slices.Equal(got, tt.want) {
+				t.Errorf("schedule(%v, %s) = %v, want %v", tt.runs, tt.order, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleShuffled(t *testing.T) {
+	tests := []struct {
+		name string
+		runs []int
+		seed int64
+	}{
+		{"even runs", []int{5, 5, 5, 5}, 42},
+		{"uneven runs", []int{1, 4, 0, 7}, 7},
+		{"single command", []int{3}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := schedule(tt.runs, shuffledOrder, rand.New(rand.NewSource(tt.seed)))
+			again := schedule(tt.runs, shuffledOrder, rand.New(rand.NewSource(tt.seed)))
+			if !slices.Equal(got, again) {
+				t.Errorf("schedule() with seed %d gave %v, then %v", tt.seed, got, again)
+			}
+
+			// every run appears exactly once
+			counts := make([]int, len(tt.runs))
+			for _, i := range got {
+				counts[i]++
+			}
+			if !slices.Equal(counts, tt.runs) {
+				t.Errorf("schedule() = %v, which has %v runs per command, want %v", got, counts, tt.runs)
+			}
+
+			// every round holds one run of every command which has runs left
+			start := 0
+			for round := 0; start < len(got); round++ {
+				var want []int
+				for i, n := range tt.runs {
+					if round < n {
+						want = append(want, i)
+					}
+				}
+				block := slices.Clone(got[start : start+len(want)])
+				slices.Sort(block)
+				if !slices.Equal(block, want) {
+					t.Errorf("round %d of %v = %v, want a permutation of %v", round, got, got[start:start+len(want)], want)
+				}
+				start += len(want)
+			}
+		})
+	}
+
+	// the rounds are shuffled anew, so some seed gives another order than the interleaved one
+	runs := []int{3, 3, 3}
+	interleaved := schedule(runs, interleavedOrder, nil)
+	shuffled := false
+	for seed := int64(0); seed < 10 && !shuffled; seed++ {
+		shuffled = !slices.Equal(schedule(runs, shuffledOrder, rand.New(rand.NewSource(seed))), interleaved)
+	}
+	if !shuffled {
+		t.Errorf("schedule() with the shuffled order always gave the interleaved order %v", interleaved)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandTargets(t *testing.T) {
+	tests := []struct {
+		name         string
+		targets      []benchmarkTarget
+		combinations []map[string]string
+		want         []benchmarkTarget
+	}{
+		{
+			"name",
+			[]benchmarkTarget{{template: "big", command: "gzip -k {name}.txt", name: "big", prepare: "rm -f {name}.txt.gz", env: []string{"OUT={name}.txt.gz"}}},
+			nil,
+			[]benchmarkTarget{{template: "big", command: "gzip -k big.txt", name: "big", prepare: "rm -f big.txt.gz", env: []string{"OUT=big.txt.gz"}}},
+		},
+		{
+			"no name",
+			[]benchmarkTarget{{template: "echo {name}", command: "echo {name}"}},
+			nil,
+			[]benchmarkTarget{{template: "echo {name}", command: "echo {name}"}},
+		},
+		{
+			"name with variables",
+			[]benchmarkTarget{{template: "zstd-{level}", command: "zstd -{level} -o {name}.zst big.txt", name: "zstd-{level}", cleanup: "rm {name}.zst", env: []string{"LEVEL={level}"}}},
+			[]map[string]string{{"level": "1"}, {"level": "9"}},
+			[]benchmarkTarget{
+				{template: "zstd-{level}", command: "zstd -1 -o zstd-1.zst big.txt", name: "zstd-1", cleanup: "rm zstd-1.zst", env: []string{"LEVEL=1"}, parameters: map[string]string{"level": "1"}},
+				{template: "zstd-{level}", command: "zstd -9 -o zstd-9.zst big.txt", name: "zstd-9", cleanup: "rm zstd-9.zst", env: []string{"LEVEL=9"}, parameters: map[string]string{"level": "9"}},
+			},
+		},
+		{
+			"variable called name",
+			[]benchmarkTarget{{template: "n", command: "echo {name}", name: "n", prepare: "touch {name}", env: []string{"NAME={name}"}}},
+			[]map[string]string{{"name": "x"}},
+			[]benchmarkTarget{{template: "n", command: "echo x", name: "n", prepare: "touch x", env: []string{"NAME=x"}, parameters: map[string]string{"name": "x"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expandTargets(tt.targets, tt.combinations); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expandTargets() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}